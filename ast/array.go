@@ -34,11 +34,13 @@ func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
 func (se *SliceExpression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("(")
-	out.WriteString(se.StartIndex.String())
+	if se.StartIndex != nil {
+		out.WriteString(se.StartIndex.String())
+	}
 	out.WriteString(":")
-	out.WriteString(se.EndIndex.String())
-	out.WriteString(")")
+	if se.EndIndex != nil {
+		out.WriteString(se.EndIndex.String())
+	}
 
 	return out.String()
 }
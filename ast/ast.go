@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strings"
 )
 
 type Node interface {
@@ -12,7 +13,6 @@ type Node interface {
 
 type Program struct {
 	Statements []Statement
-	Includes   map[string]*IncludeStatement
 }
 
 func (p *Program) TokenLiteral() string {
@@ -32,6 +32,20 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Source returns the program as source text, one statement per line. A
+// statement starting with a parenthesis or bracket would continue the line
+// before it as a call or index, so that line is ended with a semicolon.
+func (p *Program) Source() string {
+	lines := make([]string, len(p.Statements))
+	for i, s := range p.Statements {
+		lines[i] = s.String()
+		if i > 0 && lines[i] != "" && strings.ContainsAny(lines[i][:1], "([") && !strings.HasSuffix(lines[i-1], ";") {
+			lines[i-1] += ";"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -43,7 +57,16 @@ func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
-	for _, s := range bs.Statements {
+	for i, s := range bs.Statements {
+		if i > 0 {
+			// statements that don't end in a semicolon need one to be
+			// parsed apart again
+			if strings.HasSuffix(out.String(), ";") {
+				out.WriteString(" ")
+			} else {
+				out.WriteString("; ")
+			}
+		}
 		out.WriteString(s.String())
 	}
 
@@ -1,7 +1,9 @@
 package ast
 
 import (
+	"encoding/json"
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +28,63 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func dumpProgram() *Program {
+	return &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					Operator: "+",
+					Right:    &Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+				},
+			},
+		},
+	}
+}
+
+func TestDump(t *testing.T) {
+	program := dumpProgram()
+	expected := `Program
+  Statements[0]: LetStatement
+    Name: Identifier x
+    Value: InfixExpression
+      Operator: +
+      Right: Identifier y
+      Left: IntegerLiteral 1
+`
+	if got := Dump(program); got != expected {
+		t.Errorf("Dump wrong. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out, err := JSON(dumpProgram())
+	if err != nil {
+		t.Fatalf("JSON returned error: %s", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("JSON output doesn't decode: %s\n%s", err, out)
+	}
+	let := got["statements"].([]interface{})[0].(map[string]interface{})
+	if let["node"] != "LetStatement" {
+		t.Errorf("statement node wrong. got=%v", let["node"])
+	}
+	value := let["value"].(map[string]interface{})
+	if value["node"] != "InfixExpression" || value["operator"] != "+" {
+		t.Errorf("value wrong. got=%v", value)
+	}
+	left := value["left"].(map[string]interface{})
+	if left["node"] != "IntegerLiteral" || left["value"] != "1" {
+		t.Errorf("leaf wrong. got=%v", left)
+	}
+	for key := range value {
+		if key != strings.ToLower(key[:1])+key[1:] {
+			t.Errorf("key %q is not in the style of \"node\"", key)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dump returns an indented tree of node and its children, one node per line,
+// for debugging the parser.
+func Dump(node Node) string {
+	var out bytes.Buffer
+	dumpValue(&out, "", reflect.ValueOf(node), 0)
+	return out.String()
+}
+
+// leaves are printed on one line with their source text
+var leaves = map[string]bool{
+	"Identifier":         true,
+	"IntegerLiteral":     true,
+	"StringLiteral":      true,
+	"InterpolatedString": true,
+	"Boolean":            true,
+	"BreakExpression":    true,
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func dumpValue(out *bytes.Buffer, label string, v reflect.Value, depth int) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return
+	}
+	prefix := strings.Repeat("  ", depth)
+	if label != "" {
+		prefix += label + ": "
+	}
+	if v.Type().Implements(nodeType) && v.Kind() != reflect.Interface {
+		name := v.Elem().Type().Name()
+		if leaves[name] {
+			fmt.Fprintf(out, "%s%s %s\n", prefix, name, v.Interface().(Node).String())
+			return
+		}
+		fmt.Fprintf(out, "%s%s\n", prefix, name)
+		dumpFields(out, v.Elem(), depth+1)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		dumpValue(out, label, v.Elem(), depth)
+	case reflect.Ptr:
+		// nodes that aren't Nodes themselves, e.g. struct fields
+		fmt.Fprintf(out, "%s%s\n", prefix, v.Elem().Type().Name())
+		dumpFields(out, v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			if v.Len() > 0 {
+				fmt.Fprintf(out, "%s%v\n", prefix, v.Interface())
+			}
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			dumpValue(out, fmt.Sprintf("%s[%d]", label, i), v.Index(i), depth)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keyString(keys[i]) < keyString(keys[j]) })
+		for _, key := range keys {
+			if _, ok := key.Interface().(Node); ok {
+				dumpValue(out, label+" key", key, depth)
+				dumpValue(out, label+" value", v.MapIndex(key), depth)
+			} else {
+				dumpValue(out, fmt.Sprintf("%s[%v]", label, key.Interface()), v.MapIndex(key), depth)
+			}
+		}
+	default:
+		if !v.IsZero() {
+			fmt.Fprintf(out, "%s%v\n", prefix, v.Interface())
+		}
+	}
+}
+
+func keyString(key reflect.Value) string {
+	if n, ok := key.Interface().(Node); ok {
+		return n.String()
+	}
+	return fmt.Sprint(key.Interface())
+}
+
+func dumpFields(out *bytes.Buffer, v reflect.Value, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "Token" || f.PkgPath != "" {
+			continue
+		}
+		dumpValue(out, f.Name, v.Field(i), depth)
+	}
+}
+
+// JSON returns node and its children as JSON. Each node is an object with
+// its type under "node" and its fields under their names with the first
+// letter lowered, e.g. "statements"; leaves carry their source text under
+// "value".
+func JSON(node Node) ([]byte, error) {
+	return json.MarshalIndent(jsonValue(reflect.ValueOf(node)), "", "  ")
+}
+
+func jsonValue(v reflect.Value) interface{} {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if v.Type().Implements(nodeType) && v.Kind() != reflect.Interface {
+		name := v.Elem().Type().Name()
+		if leaves[name] {
+			return map[string]interface{}{"node": name, "value": v.Interface().(Node).String()}
+		}
+		fields := jsonFields(v.Elem())
+		fields["node"] = name
+		return fields
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return jsonValue(v.Elem())
+	case reflect.Ptr:
+		return jsonFields(v.Elem())
+	case reflect.Slice:
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = jsonValue(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keyString(keys[i]) < keyString(keys[j]) })
+		pairs := make([]interface{}, len(keys))
+		for i, key := range keys {
+			pairs[i] = map[string]interface{}{"key": jsonValue(key), "value": jsonValue(v.MapIndex(key))}
+		}
+		return pairs
+	default:
+		return v.Interface()
+	}
+}
+
+func jsonFields(v reflect.Value) map[string]interface{} {
+	fields := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "Token" || f.PkgPath != "" {
+			continue
+		}
+		fields[jsonKey(f.Name)] = jsonValue(v.Field(i))
+	}
+	return fields
+}
+
+// jsonKey returns the key a field is written under, so that every key is in
+// the same style as "node" and "value".
+func jsonKey(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -9,6 +9,8 @@ import (
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Order holds the keys of Pairs in source order
+	Order []Expression
 }
 
 func (h *HashLiteral) expressionNode()      {}
@@ -17,8 +19,8 @@ func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range h.Pairs {
-		pairs = append(pairs, key.String()+"->"+value.String())
+	for _, key := range h.Order {
+		pairs = append(pairs, key.String()+" -> "+h.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
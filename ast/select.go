@@ -0,0 +1,63 @@
+package ast
+
+import (
+	"bytes"
+	"monkey/token"
+)
+
+// SelectExpression waits on several channel operations and runs the body of
+// the first one that can proceed, or Default when none can.
+type SelectExpression struct {
+	Token   token.Token
+	Cases   []*SelectCase
+	Default *BlockStatement
+}
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SelectExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("select { ")
+	for _, c := range se.Cases {
+		out.WriteString(c.String())
+		out.WriteString(" ")
+	}
+	if se.Default != nil {
+		out.WriteString("default { ")
+		out.WriteString(se.Default.String())
+		out.WriteString(" } ")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// SelectCase is a `case ch.recv() as name { ... }` or `case ch.send(value)
+// { ... }` arm of a select expression. Binding is only set for recv.
+type SelectCase struct {
+	Token   token.Token
+	Call    *MethodCallExpression
+	Binding *Identifier
+	Body    *BlockStatement
+}
+
+// IsSend reports whether the case sends rather than receives.
+func (sc *SelectCase) IsSend() bool {
+	return sc.Call.Call.(*CallExpression).Function.String() == "send"
+}
+
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("case ")
+	out.WriteString(sc.Call.String())
+	if sc.Binding != nil {
+		out.WriteString(" as ")
+		out.WriteString(sc.Binding.String())
+	}
+	out.WriteString(" { ")
+	out.WriteString(sc.Body.String())
+	out.WriteString(" }")
+	return out.String()
+}
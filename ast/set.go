@@ -0,0 +1,28 @@
+package ast
+
+import (
+	"bytes"
+	"monkey/token"
+	"strings"
+)
+
+type SetLiteral struct {
+	Token   token.Token
+	Members []Expression
+}
+
+func (s *SetLiteral) expressionNode()      {}
+func (s *SetLiteral) TokenLiteral() string { return s.Token.Literal }
+func (s *SetLiteral) String() string {
+	var out bytes.Buffer
+
+	members := []string{}
+	for _, m := range s.Members {
+		members = append(members, m.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(members, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
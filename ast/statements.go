@@ -3,6 +3,8 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"path"
+	"strings"
 )
 
 type Statement interface {
@@ -66,11 +68,15 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// IncludeStatement and ImportStatement record where they were parsed so the
+// module can be resolved when the statement is evaluated: Dir is the
+// directory of the file containing the statement and IncludePaths the extra
+// search directories the parser was configured with.
 type IncludeStatement struct {
-	Token       token.Token
-	IncludePath Expression
-	IsModule    bool
-	Program     *Program
+	Token        token.Token
+	IncludePath  Expression
+	Dir          string
+	IncludePaths []string
 }
 
 func (is *IncludeStatement) statementNode()       {}
@@ -85,10 +91,13 @@ func (is *IncludeStatement) String() string {
 	return out.String()
 }
 
+// AssignExpression rebinds a variable, or a struct field when Object is set,
+// e.g. `self.x = 1`.
 type AssignExpression struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token  token.Token
+	Object Expression
+	Name   *Identifier
+	Value  Expression
 }
 
 func (ae *AssignExpression) expressionNode()      {}
@@ -97,9 +106,87 @@ func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
 func (ae *AssignExpression) String() string {
 	var out bytes.Buffer
 
+	if ae.Object != nil {
+		out.WriteString(ae.Object.String())
+		out.WriteString(".")
+	}
 	out.WriteString(ae.Name.String())
 	out.WriteString(" = ")
 	out.WriteString(ae.Value.String())
 
 	return out.String()
 }
+
+// ImportStatement covers both `import "path/mod" as m` and
+// `from "path/mod" import a, b`. Names is only set for the latter.
+type ImportStatement struct {
+	Token        token.Token
+	Path         string
+	Alias        *Identifier
+	Names        []*Identifier
+	Dir          string
+	IncludePaths []string
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	if len(is.Names) > 0 {
+		names := []string{}
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("from \"" + is.Path + "\" import ")
+		out.WriteString(strings.Join(names, ", "))
+		return out.String()
+	}
+	out.WriteString("import \"" + is.Path + "\"")
+	if is.Alias != nil {
+		out.WriteString(" as " + is.Alias.String())
+	}
+	return out.String()
+}
+
+// Binding is the name an import statement binds the module to: the alias
+// if one was given, otherwise the last element of the path.
+func (is *ImportStatement) Binding() string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+	return path.Base(is.Path)
+}
+
+// ExportStatement marks bindings as visible to importers of the module. It
+// wraps either a let statement or a list of previously bound names.
+type ExportStatement struct {
+	Token     token.Token
+	Statement *LetStatement
+	Names     []*Identifier
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	if es.Statement != nil {
+		return es.TokenLiteral() + " " + es.Statement.String()
+	}
+	names := []string{}
+	for _, n := range es.Names {
+		names = append(names, n.String())
+	}
+	return es.TokenLiteral() + " " + strings.Join(names, ", ")
+}
+
+// Exported returns the names the statement exports.
+func (es *ExportStatement) Exported() []string {
+	if es.Statement != nil {
+		return []string{es.Statement.Name.Value}
+	}
+	names := []string{}
+	for _, n := range es.Names {
+		names = append(names, n.Value)
+	}
+	return names
+}
@@ -1,6 +1,10 @@
 package ast
 
-import "monkey/token"
+import (
+	"bytes"
+	"monkey/token"
+	"strings"
+)
 
 type StringLiteral struct {
 	Token token.Token
@@ -9,7 +13,7 @@ type StringLiteral struct {
 
 func (s *StringLiteral) expressionNode()      {}
 func (s *StringLiteral) TokenLiteral() string { return s.Token.Literal }
-func (s *StringLiteral) String() string       { return s.Token.Literal }
+func (s *StringLiteral) String() string       { return `"` + s.Value + `"` }
 
 type InterpolatedString struct {
 	Token   token.Token
@@ -19,4 +23,25 @@ type InterpolatedString struct {
 
 func (is *InterpolatedString) expressionNode()      {}
 func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
-func (is *InterpolatedString) String() string       { return is.Token.Literal }
+
+// String puts the source of each expression back in place of the {0}, {1}, ...
+// placeholders in Value.
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString("'")
+	rest := is.Value
+	for key := byte('0'); ; key++ {
+		expr, ok := is.ExprMap[key]
+		placeholder := "{" + string(key) + "}"
+		i := strings.Index(rest, placeholder)
+		if !ok || i < 0 {
+			break
+		}
+		out.WriteString(rest[:i])
+		out.WriteString("{" + expr.String() + "}")
+		rest = rest[i+len(placeholder):]
+	}
+	out.WriteString(rest)
+	out.WriteString("'")
+	return out.String()
+}
@@ -9,6 +9,8 @@ import (
 type StructLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Order holds the keys of Pairs in source order
+	Order []Expression
 }
 
 func (s *StructLiteral) expressionNode()      {}
@@ -17,13 +19,73 @@ func (s *StructLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range s.Pairs {
-		pairs = append(pairs, key.String()+"->"+value.String())
+	for _, key := range s.Order {
+		pairs = append(pairs, key.String()+" -> "+s.Pairs[key].String())
 	}
-	out.WriteString("(")
+	out.WriteString("struct(")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString(")")
 
 	return out.String()
 
 }
+
+// StructStatement declares a named struct type:
+// `struct Point { x, y = 0; fn dist(self, other) { ... } }`. A type can embed
+// another, `struct Tracked(Point) { moves }`, inheriting its fields and methods.
+type StructStatement struct {
+	Token   token.Token
+	Name    *Identifier
+	Embeds  *Identifier
+	Fields  []*StructField
+	Methods []*StructMethod
+}
+
+// StructField is a field of a struct type with an optional default value.
+type StructField struct {
+	Name    *Identifier
+	Default Expression
+}
+
+// StructMethod is a method declared in a struct type. The first parameter of
+// Function receives the instance the method is called on.
+type StructMethod struct {
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("struct ")
+	out.WriteString(ss.Name.String())
+	if ss.Embeds != nil {
+		out.WriteString("(" + ss.Embeds.String() + ")")
+	}
+	out.WriteString(" { ")
+	members := []string{}
+	fields := []string{}
+	for _, f := range ss.Fields {
+		if f.Default != nil {
+			fields = append(fields, f.Name.String()+" = "+f.Default.String())
+		} else {
+			fields = append(fields, f.Name.String())
+		}
+	}
+	if len(fields) > 0 {
+		members = append(members, strings.Join(fields, ", "))
+	}
+	for _, m := range ss.Methods {
+		params := []string{}
+		for _, p := range m.Function.Parameters {
+			params = append(params, p.String())
+		}
+		members = append(members, "fn "+m.Name.String()+"("+strings.Join(params, ", ")+") { "+m.Function.Body.String()+" }")
+	}
+	out.WriteString(strings.Join(members, "; "))
+	out.WriteString(" }")
+
+	return out.String()
+}
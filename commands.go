@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/lsp"
+	"monkey/parser"
+	"monkey/tester"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// subcommand is a tool run as `monkey name [flags] [files]`.
+type subcommand struct {
+	usage string
+	help  string
+	run   func(args []string) int
+}
+
+var subcommands map[string]subcommand
+
+func init() {
+	subcommands = map[string]subcommand{
+		"fmt":    {"[-l] [-w] [files]", "reformat source files", runFmt},
+		"check":  {"[files]", "report parse errors without running", runCheck},
+		"tokens": {"[-json] [file]", "print the lexer's tokens", runTokens},
+		"ast":    {"[-json] [file]", "print the syntax tree", runAST},
+		"test":   {"[-I dir] [paths]", "run the test functions in *_test.my files", runTest},
+		"lint":   {"[-I dir] [files]", "report likely mistakes without running", runLint},
+		"lsp":    {"[-I dir]", "serve the Language Server Protocol on standard input and output", runLSP},
+	}
+}
+
+// subcommandUsage lists the subcommands for usage.
+func subcommandUsage() string {
+	names := []string{}
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&out, "       monkey %s %s\n", name, subcommands[name].usage)
+	}
+	return out.String()
+}
+
+// newFlagSet returns the flag set for the subcommand name.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("monkey "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: monkey %s %s\n\n%s\n", name, subcommands[name].usage, subcommands[name].help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// readSource returns the contents of filename, or of standard input when
+// filename is empty or -. The name to report errors under is returned too.
+func readSource(filename string) (string, []byte, error) {
+	if filename == "" || filename == "-" {
+		src, err := ioutil.ReadAll(os.Stdin)
+		return "<stdin>", src, err
+	}
+	src, err := ioutil.ReadFile(filename)
+	return filename, src, err
+}
+
+// eachSource calls fn with each named file, or with standard input when no
+// files are named, and returns the worst exit status fn returned.
+func eachSource(files []string, fn func(name string, src []byte) int) int {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, file := range files {
+		name, src, err := readSource(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey:", err)
+			status = exitError
+			continue
+		}
+		if s := fn(name, src); s > status {
+			status = s
+		}
+	}
+	return status
+}
+
+// printDiagnostics prints parse errors in the file name with the lines of
+// src they were found on.
+func printDiagnostics(name string, src []byte, diagnostics []*parser.Diagnostic) {
+	for _, d := range diagnostics {
+		fmt.Fprint(os.Stderr, d.Format(name, string(src)))
+	}
+}
+
+func runFmt(args []string) int {
+	fs := newFlagSet("fmt")
+	list := fs.Bool("l", false, "list files whose formatting differs")
+	write := fs.Bool("w", false, "write the result back to the file instead of printing it")
+	fs.Parse(args)
+
+	return eachSource(fs.Args(), func(name string, src []byte) int {
+		out, diagnostics := format.Source(src)
+		if diagnostics != nil {
+			printDiagnostics(name, src, diagnostics)
+			return exitParseError
+		}
+		changed := !bytes.Equal(src, out)
+		if *list && changed {
+			fmt.Println(name)
+		}
+		if *write && name != "<stdin>" {
+			if changed {
+				if err := ioutil.WriteFile(name, out, 0644); err != nil {
+					fmt.Fprintln(os.Stderr, "monkey:", err)
+					return exitError
+				}
+			}
+		} else if !*list {
+			os.Stdout.Write(out)
+		}
+		return 0
+	})
+}
+
+func runCheck(args []string) int {
+	fs := newFlagSet("check")
+	fs.Parse(args)
+
+	return eachSource(fs.Args(), func(name string, src []byte) int {
+		p := parser.New(lexer.New(string(src)), "")
+		p.ParseProgram()
+		if len(p.Diagnostics()) != 0 {
+			printDiagnostics(name, src, p.Diagnostics())
+			return exitParseError
+		}
+		return 0
+	})
+}
+
+func runTokens(args []string) int {
+	fs := newFlagSet("tokens")
+	asJSON := fs.Bool("json", false, "print the tokens as a JSON array")
+	fs.Parse(args)
+
+	return eachSource(fs.Args(), func(name string, src []byte) int {
+		l := lexer.New(string(src))
+		tokens := []token.Token{}
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			tokens = append(tokens, tok)
+		}
+		if !*asJSON {
+			for _, tok := range tokens {
+				fmt.Printf("%-8s %q\n", tok.Type, tok.Literal)
+			}
+			return 0
+		}
+		type jsonToken struct {
+			Type    token.TokenType `json:"type"`
+			Literal string          `json:"literal"`
+			Line    int             `json:"line"`
+			Column  int             `json:"column"`
+		}
+		out := make([]jsonToken, len(tokens))
+		for i, tok := range tokens {
+			out[i] = jsonToken{tok.Type, tok.Literal, tok.Line, tok.Column}
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey:", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+		return 0
+	})
+}
+
+func runAST(args []string) int {
+	fs := newFlagSet("ast")
+	asJSON := fs.Bool("json", false, "print the tree as JSON")
+	fs.Parse(args)
+
+	return eachSource(fs.Args(), func(name string, src []byte) int {
+		p := parser.New(lexer.New(string(src)), "")
+		program := p.ParseProgram()
+		if len(p.Diagnostics()) != 0 {
+			printDiagnostics(name, src, p.Diagnostics())
+			return exitParseError
+		}
+		if !*asJSON {
+			fmt.Print(ast.Dump(program))
+			return 0
+		}
+		data, err := ast.JSON(program)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey:", err)
+			return exitError
+		}
+		fmt.Println(string(data))
+		return 0
+	})
+}
+
+func runTest(args []string) int {
+	fs := newFlagSet("test")
+	var includePaths pathList
+	fs.Var(&includePaths, "I", "add `dir` to the module search path; may be repeated")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := tester.Find(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "monkey:", err)
+		return exitError
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+	r := tester.Run(files, tester.Options{Out: os.Stdout, Stdout: os.Stdout, IncludePaths: includePaths})
+	if r.Failed > 0 {
+		fmt.Printf("FAIL: %d passed, %d failed\n", r.Passed, r.Failed)
+		return exitError
+	}
+	fmt.Printf("PASS: %d passed\n", r.Passed)
+	return 0
+}
+
+func runLint(args []string) int {
+	fs := newFlagSet("lint")
+	var includePaths pathList
+	fs.Var(&includePaths, "I", "add `dir` to the module search path; may be repeated")
+	fs.Parse(args)
+
+	return eachSource(fs.Args(), func(name string, src []byte) int {
+		// includes are resolved to tell directory modules from files
+		dir := "."
+		if name != "<stdin>" {
+			dir = filepath.Dir(name)
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		p := parser.New(lexer.New(string(src)), dir)
+		p.SetIncludePaths(includePaths)
+		program := p.ParseProgram()
+		if len(p.Diagnostics()) != 0 {
+			printDiagnostics(name, src, p.Diagnostics())
+			return exitParseError
+		}
+		problems := lint.Program(program)
+		for _, problem := range problems {
+			fmt.Printf("%s:%s\n", name, problem)
+		}
+		if len(problems) > 0 {
+			return exitError
+		}
+		return 0
+	})
+}
+
+func runLSP(args []string) int {
+	fs := newFlagSet("lsp")
+	var includePaths pathList
+	fs.Var(&includePaths, "I", "add `dir` to the module search path; may be repeated")
+	fs.Parse(args)
+
+	server := lsp.NewServer(os.Stdin, os.Stdout)
+	server.IncludePaths = includePaths
+	if err := server.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "monkey lsp:", err)
+		return exitError
+	}
+	return 0
+}
@@ -80,7 +80,7 @@ func (a *Array) Filter(args ...Object) Object {
 	}
 	arr := &Array{}
 	arr.Members = []Object{}
-	s := NewScope(nil)
+	s := newDetachedScope(block.Scope)
 	for _, argument := range a.Members {
 		s.Set(block.Literal.Parameters[0].(*ast.Identifier).Value, argument)
 		r, ok := Eval(block.Literal.Body, s).(*Boolean)
@@ -125,7 +125,7 @@ func (a *Array) Map(args ...Object) Object {
 		return newError(INPUTERROR, args[0].Type(), "map")
 	}
 	arr := &Array{}
-	s := NewScope(nil)
+	s := newDetachedScope(block.Scope)
 	for _, argument := range a.Members {
 		s.Set(block.Literal.Parameters[0].(*ast.Identifier).Value, argument)
 		r := Eval(block.Literal.Body, s)
@@ -196,7 +196,7 @@ func (a *Array) Reduce(args ...Object) Object {
 	if !ok {
 		return newError(INPUTERROR, args[0].Type(), "map")
 	}
-	s := NewScope(nil)
+	s := newDetachedScope(block.Scope)
 	start := 1
 	if l == 1 {
 		s.Set(block.Literal.Parameters[0].(*ast.Identifier).Value, a.Members[0])
@@ -0,0 +1,210 @@
+package eval
+
+import (
+	"monkey/ast"
+	"strings"
+)
+
+// comparisons are the operators whose operands an assert failure shows
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, ">": true}
+
+// assert fails unless its argument is true. Calls to assert are evaluated by
+// evalAssert, which can say more; this is its Fn for help() and hosts.
+func assert(scope *Scope, args ...Object) Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(ARGUMENTERROR, "1 or 2", len(args))
+	}
+	if objectToNativeBoolean(args[0]) {
+		return NULL
+	}
+	return assertionFailed(args[1:], "got "+args[0].Inspect())
+}
+
+// assertEq fails unless its first two arguments are equal.
+func assertEq(scope *Scope, args ...Object) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError(ARGUMENTERROR, "2 or 3", len(args))
+	}
+	for _, arg := range args {
+		if arg.Type() == ERROR_OBJ {
+			return arg
+		}
+	}
+	equal, err := equalObjects(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if equal {
+		return NULL
+	}
+	got, want := args[0].Inspect(), args[1].Inspect()
+	if got == want {
+		got += " (" + typeName(args[0]) + ")"
+		want += " (" + typeName(args[1]) + ")"
+	}
+	return assertionFailed(args[2:], "got "+got+", want "+want)
+}
+
+// assertRaises fails unless calling its first argument raises an error.
+func assertRaises(scope *Scope, args ...Object) Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(ARGUMENTERROR, "1 or 2", len(args))
+	}
+	want := ""
+	if len(args) == 2 {
+		s, ok := args[1].(*String)
+		if !ok {
+			return newError(INPUTERROR, args[1].Type(), "assert_raises")
+		}
+		want = s.Value
+	}
+	var result Object
+	switch fn := args[0].(type) {
+	case *Function:
+		result = applyFunction(fn)
+	case *Builtin:
+		result = fn.call(scope)
+	default:
+		return newError(INPUTERROR, args[0].Type(), "assert_raises")
+	}
+	// exit() and exceeded limits stop the program rather than
+	// being raised
+	if failed := scope.runtime().failed(); failed != nil {
+		return failed
+	}
+	err, ok := result.(*Error)
+	if !ok {
+		return assertionFailed(nil, "no error raised, got "+result.Inspect())
+	}
+	if !strings.Contains(err.Message, want) {
+		return assertionFailed(nil, "error '"+err.Message+"' doesn't contain '"+want+"'")
+	}
+	return &String{Value: err.Message}
+}
+
+// evalAssert evaluates a call to assert, so that a failure can name the
+// condition and, when it's a comparison, show the values compared.
+func evalAssert(call *ast.CallExpression, s *Scope) Object {
+	if len(call.Arguments) < 1 || len(call.Arguments) > 2 {
+		return newError(ARGUMENTERROR, "1 or 2", len(call.Arguments))
+	}
+	var cond Object
+	detail := ""
+	if infix, ok := call.Arguments[0].(*ast.InfixExpression); ok && comparisons[infix.Operator] {
+		left := Eval(infix.Left, s)
+		if left.Type() == ERROR_OBJ {
+			return left
+		}
+		right := Eval(infix.Right, s)
+		if right.Type() == ERROR_OBJ {
+			return right
+		}
+		cond = evalInfix(s, infix.Operator, left, right)
+		detail = ": " + left.Inspect() + " " + infix.Operator + " " + right.Inspect()
+	} else {
+		cond = Eval(call.Arguments[0], s)
+	}
+	if cond.Type() == ERROR_OBJ {
+		return cond
+	}
+	message := evalArgs(call.Arguments[1:], s)
+	for _, arg := range message {
+		if arg.Type() == ERROR_OBJ {
+			return arg
+		}
+	}
+	if objectToNativeBoolean(cond) {
+		return NULL
+	}
+	return assertionFailed(message, call.Arguments[0].String()+" is "+cond.Inspect()+detail)
+}
+
+// assertionFailed returns an assertion error with detail, preceded by the
+// message passed to the assertion if there is one.
+func assertionFailed(message []Object, detail string) Object {
+	if len(message) > 0 {
+		if s, ok := message[0].(*String); ok {
+			detail = s.Value + ": " + detail
+		} else {
+			detail = message[0].Inspect() + ": " + detail
+		}
+	}
+	return newError(ASSERTERROR, detail)
+}
+
+// equalObjects reports whether a and b are equal, comparing collections and
+// structs by their contents. Structs with an __eq__ method decide for
+// themselves.
+func equalObjects(a, b Object) (bool, Object) {
+	if _, ok := protocolMethod(a, "__eq__"); ok {
+		eq := evalInfix(nil, "==", a, b)
+		if eq.Type() == ERROR_OBJ {
+			return false, eq
+		}
+		return objectToNativeBoolean(eq), nil
+	}
+	if a.Type() != b.Type() {
+		return false, nil
+	}
+	switch a := a.(type) {
+	case *Array:
+		b := b.(*Array)
+		if len(a.Members) != len(b.Members) {
+			return false, nil
+		}
+		for i := range a.Members {
+			if equal, err := equalObjects(a.Members[i], b.Members[i]); !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *Hash:
+		b := b.(*Hash)
+		if len(a.Pairs) != len(b.Pairs) {
+			return false, nil
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok {
+				return false, nil
+			}
+			if equal, err := equalObjects(pair.Value, other.Value); !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *Set:
+		b := b.(*Set)
+		if len(a.Members) != len(b.Members) {
+			return false, nil
+		}
+		for key := range a.Members {
+			if _, ok := b.Members[key]; !ok {
+				return false, nil
+			}
+		}
+		return true, nil
+	case *Struct:
+		b := b.(*Struct)
+		if a.typ != b.typ {
+			return false, nil
+		}
+		fields, other := a.fieldValues(), b.fieldValues()
+		if len(fields) != len(other) {
+			return false, nil
+		}
+		for name, value := range fields {
+			o, ok := other[name]
+			if !ok {
+				return false, nil
+			}
+			if equal, err := equalObjects(value, o); !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *Integer, *String, *Boolean:
+		return objectToNativeBoolean(evalInfix(nil, "==", a, b)), nil
+	}
+	return a == b, nil
+}
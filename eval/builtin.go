@@ -3,23 +3,62 @@ package eval
 import (
 	"fmt"
 	"os"
-	"reflect"
 	"strconv"
-	"strings"
 )
 
-type BuiltinFunc func(args ...Object) Object
+type BuiltinFunc func(scope *Scope, args ...Object) Object
 
+// Builtin is a function implemented in Go. Name, Arity and Doc describe it
+// for help(). Calls with a different number of arguments than Arity fail
+// before Fn is called, unless Arity is Variadic: Fn then checks them itself.
 type Builtin struct {
-	Fn BuiltinFunc
+	Fn    BuiltinFunc
+	Name  string
+	Arity int
+	Doc   string
 }
 
 var builtins map[string]*Builtin
 
+// call calls b, failing first when it's given the wrong number of arguments
+// for its Arity.
+func (b *Builtin) call(scope *Scope, args ...Object) Object {
+	if err := checkArity(b.Arity, args); err != nil {
+		return err
+	}
+	return b.Fn(scope, args...)
+}
+
+// BuiltinModule groups related builtins under a name, e.g. json.parse.
+type BuiltinModule struct {
+	Name      string
+	Functions map[string]*Builtin
+}
+
+func (m *BuiltinModule) Inspect() string  { return "builtin module: " + m.Name }
+func (m *BuiltinModule) Type() ObjectType { return BUILTIN_MODULE_OBJ }
+func (m *BuiltinModule) CallMethod(method string, args ...Object) Object {
+	return m.call(nil, method, args...)
+}
+
+func (m *BuiltinModule) call(scope *Scope, method string, args ...Object) Object {
+	if fn, ok := m.Functions[method]; ok {
+		return fn.call(scope, args...)
+	}
+	return newError(NOMETHODERROR, method, m.Name)
+}
+
+var builtinModules map[string]*BuiltinModule
+
 func init() {
+	builtinModules = map[string]*BuiltinModule{
+		"json": jsonModule,
+	}
 	builtins = map[string]*Builtin{
 		"abs": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "abs(n) returns the absolute value of the integer n.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
@@ -33,8 +72,28 @@ func init() {
 				return &Integer{Value: i.Value * -1}
 			},
 		},
+		"assert": &Builtin{
+			Arity: Variadic,
+			Doc: "assert(condition[, message]) fails with an assertion error when condition is false or null. " +
+				"The error shows the condition and, for a comparison, the values compared.",
+			Fn: assert,
+		},
+		"assert_eq": &Builtin{
+			Arity: Variadic,
+			Doc: "assert_eq(actual, expected[, message]) fails with an assertion error unless actual equals expected. " +
+				"Arrays, hashes, sets and structs are compared by their contents.",
+			Fn: assertEq,
+		},
+		"assert_raises": &Builtin{
+			Arity: Variadic,
+			Doc: "assert_raises(fn[, text]) calls fn and fails with an assertion error unless it raises an error, " +
+				"containing text if given. It returns the error's message.",
+			Fn: assertRaises,
+		},
 		"addm": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 3,
+			Doc:   "addm(struct, name, fn) adds fn to struct as the method name.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 3 {
 					return newError(ARGUMENTERROR, "2", len(args))
 				}
@@ -54,8 +113,31 @@ func init() {
 				return NULL
 			},
 		},
+		"array": &Builtin{
+			Arity: 1,
+			Doc:   "array(collection) returns an array holding the members of an array, a set or a struct with an __iter__ method.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 1 {
+					return newError(ARGUMENTERROR, "1", len(args))
+				}
+				members, err := iterate(args[0], "array")
+				if err != nil {
+					return err
+				}
+				return &Array{Members: append([]Object{}, members...)}
+			},
+		},
+		"chan": &Builtin{
+			Arity: Variadic,
+			Doc:   "chan([capacity]) returns a new channel, buffered when capacity is given.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				return newChannel(args...)
+			},
+		},
 		"chr": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "chr(n) returns the one character string for the byte value n.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
@@ -69,14 +151,48 @@ func init() {
 				return &String{Value: string(i.Value)}
 			},
 		},
+		"exit": &Builtin{
+			Arity: Variadic,
+			Doc:   "exit([code]) stops the program with the exit status code, 0 by default.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) > 1 {
+					return newError(ARGUMENTERROR, "0 or 1", len(args))
+				}
+				code := 0
+				if len(args) == 1 {
+					i, ok := args[0].(*Integer)
+					if !ok {
+						return newError(INPUTERROR, args[0].Type(), "exit")
+					}
+					code = int(i.Value)
+				}
+				// failing the runtime stops evaluation wherever exit was called
+				return scope.runtime().fail(&Error{Message: fmt.Sprintf("exit(%d)", code), exit: true, code: code})
+			},
+		},
+		"mutex": &Builtin{
+			Arity: 0,
+			Doc:   "mutex() returns a new unlocked mutex with lock(), try_lock() and unlock() methods.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 0 {
+					return newError(ARGUMENTERROR, "0", len(args))
+				}
+				return &Mutex{ch: make(chan struct{}, 1)}
+			},
+		},
 		"open": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "open(path) opens the file at path and returns a file object.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
 				s, ok := args[0].(*String)
 				if !ok {
-					return newError(INPUTERROR, args[0].Type(), "ord")
+					return newError(INPUTERROR, args[0].Type(), "open")
+				}
+				if err := scope.rt.checkFile(s.Value); err != nil {
+					return err
 				}
 				f, err := os.Open(s.Value)
 				if err != nil {
@@ -86,7 +202,9 @@ func init() {
 			},
 		},
 		"int": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "int(value) converts a string or integer to an integer.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
@@ -103,22 +221,119 @@ func init() {
 				return newError(INPUTERROR, args[0].Type(), "int")
 			},
 		},
+		"re": &Builtin{
+			Arity: 1,
+			Doc:   "re(pattern) compiles pattern into a regex object.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 1 {
+					return newError(ARGUMENTERROR, "1", len(args))
+				}
+				s, ok := args[0].(*String)
+				if !ok {
+					return newError(INPUTERROR, args[0].Type(), "re")
+				}
+				return newRegex(s.Value)
+			},
+		},
+		"reload": &Builtin{
+			Arity: 1,
+			Doc:   "reload(module) re-reads an imported module from disk.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 1 {
+					return newError(ARGUMENTERROR, "1", len(args))
+				}
+				m, ok := args[0].(*IncludedObject)
+				if !ok {
+					return newError(INPUTERROR, args[0].Type(), "reload")
+				}
+				return reloadModule(m)
+			},
+		},
+		"set": &Builtin{
+			Arity: Variadic,
+			Doc:   "set([array]) returns a new set, optionally holding the members of array.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) > 1 {
+					return newError(ARGUMENTERROR, "0 or 1", len(args))
+				}
+				if len(args) == 0 {
+					set, _ := NewSet()
+					return set
+				}
+				members, err := iterate(args[0], "set")
+				if err != nil {
+					return err
+				}
+				set, err := NewSet(members...)
+				if err != nil {
+					return err
+				}
+				return set
+			},
+		},
+		"spawn": &Builtin{
+			Arity: Variadic,
+			Doc:   "spawn(fn, args...) calls fn with args on a new task and returns the task. task.join() waits for its result.",
+			Fn:    spawn,
+		},
 		"str": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "str(value) returns value as a string.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
 				switch input := args[0].(type) {
 				case *String:
 					return input
+				case *Struct:
+					if input.hasMethod("__str__") {
+						return callProtocol(input, "__str__", STRING_OBJ)
+					}
+				}
+				return &String{Value: args[0].Inspect()}
+			},
+		},
+		"implements": &Builtin{
+			Arity: 2,
+			Doc: "implements(value, methods) reports whether value has every method named in the array methods. " +
+				"methods can also be a struct type, whose methods value must then have.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				var want []string
+				switch m := args[1].(type) {
+				case *Array:
+					for _, name := range m.Members {
+						s, ok := name.(*String)
+						if !ok {
+							return newError(INPUTERROR, name.Type(), "implements")
+						}
+						want = append(want, s.Value)
+					}
+				case *StructType:
+					for t := m; t != nil; t = t.Embeds {
+						for name := range t.Methods {
+							want = append(want, name)
+						}
+					}
 				default:
-					return &String{Value: input.Inspect()}
+					return newError(INPUTERROR, args[1].Type(), "implements")
 				}
-				return newError(INPUTERROR, args[0].Type(), "str")
+				has := make(map[string]bool)
+				for _, name := range MethodNames(args[0]) {
+					has[name] = true
+				}
+				for _, name := range want {
+					if !has[name] {
+						return FALSE
+					}
+				}
+				return TRUE
 			},
 		},
 		"len": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "len(value) returns the length of a string, array, set or a struct with a __len__ method.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
@@ -127,29 +342,41 @@ func init() {
 					return &Integer{Value: int64(len(arg.Value))}
 				case *Array:
 					return &Integer{Value: int64(len(arg.Members))}
-
+				case *Set:
+					return arg.Len()
+				case *Struct:
+					if arg.hasMethod("__len__") {
+						return callProtocol(arg, "__len__", INTEGER_OBJ)
+					}
 				}
 				return newError(NOMETHODERROR, "len", args[0].Type())
 			},
 		},
+		"help": &Builtin{
+			Arity: Variadic,
+			Doc:   "help([name]) describes the builtin name, or lists every builtin. help(value, name) describes a method registered on the type of value.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				return help(args...)
+			},
+		},
 		"methods": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "methods(value) returns the names of the methods value supports.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
 				methods := &Array{}
-				t := reflect.TypeOf(args[0])
-				for i := 0; i < t.NumMethod(); i++ {
-					m := t.Method(i).Name
-					if !(m == "Type" || m == "CallMethod" || m == "HashKey" || m == "Inspect") {
-						methods.Members = append(methods.Members, &String{Value: strings.ToLower(m)})
-					}
+				for _, m := range MethodNames(args[0]) {
+					methods.Members = append(methods.Members, &String{Value: m})
 				}
 				return methods
 			},
 		},
 		"ord": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "ord(s) returns the byte value of the one character string s.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
@@ -164,18 +391,43 @@ func init() {
 			},
 		},
 		"puts": &Builtin{
-			Fn: func(args ...Object) Object {
-				fmt.Println(args[0].Inspect())
+			Arity: 1,
+			Doc:   "puts(value) writes value followed by a newline to the output.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				fmt.Fprintln(scope.Writer(), args[0].Inspect())
+				return NULL
+			},
+		},
+		"eputs": &Builtin{
+			Arity: 1,
+			Doc:   "eputs(value) writes value followed by a newline to the error output.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				fmt.Fprintln(scope.ErrWriter(), args[0].Inspect())
 				return NULL
 			},
 		},
+		"waitgroup": &Builtin{
+			Arity: 0,
+			Doc:   "waitgroup() returns a new wait group with add(n), done() and wait() methods.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 0 {
+					return newError(ARGUMENTERROR, "0", len(args))
+				}
+				return newWaitGroup()
+			},
+		},
 		"type": &Builtin{
-			Fn: func(args ...Object) Object {
+			Arity: 1,
+			Doc:   "type(value) returns the type name of value.",
+			Fn: func(scope *Scope, args ...Object) Object {
 				if len(args) != 1 {
 					return newError(ARGUMENTERROR, "1", len(args))
 				}
-				return &String{Value: fmt.Sprintf("%s", args[0].Type())}
+				return &String{Value: typeName(args[0])}
 			},
 		},
 	}
+	for name, b := range builtins {
+		b.Name = name
+	}
 }
@@ -0,0 +1,382 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/ast"
+	"reflect"
+	"sync"
+)
+
+// scopedCaller is implemented by objects whose methods need the caller's
+// scope, e.g. to stop blocking when the runtime's context is done.
+type scopedCaller interface {
+	call(scope *Scope, method string, args ...Object) Object
+}
+
+// runtime returns the scope's runtime. Methods called without a scope get an
+// unlimited one.
+func (s *Scope) runtime() *runtime {
+	if s == nil {
+		return &runtime{}
+	}
+	return s.rt
+}
+
+// Task is the handle returned by spawn().
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+func (t *Task) Inspect() string  { return "task" }
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) CallMethod(method string, args ...Object) Object {
+	return t.call(nil, method, args...)
+}
+
+func (t *Task) call(scope *Scope, method string, args ...Object) Object {
+	switch method {
+	case "join":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return t.join(scope)
+	case "done":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		select {
+		case <-t.done:
+			return TRUE
+		default:
+			return FALSE
+		}
+	}
+	return newError(NOMETHODERROR, method, t.Type())
+}
+
+// join waits for the task and returns its result. An error in the task is
+// returned as the result.
+func (t *Task) join(scope *Scope) Object {
+	select {
+	case <-t.done:
+		return t.result
+	case <-scope.runtime().done():
+		return scope.runtime().interrupted()
+	}
+}
+
+// spawn runs fn with args on a new goroutine. The task gets its own runtime
+// with the same limits as the caller, and its steps count against the
+// program's.
+func spawn(scope *Scope, args ...Object) Object {
+	if len(args) < 1 {
+		return newError(ARGUMENTERROR, "at least 1", len(args))
+	}
+	if scope.runtime().limits.NoSpawn {
+		return newError(PERMISSIONERROR, "spawn is disabled")
+	}
+	task := &Task{done: make(chan struct{})}
+	rt := scope.runtime().fork()
+	var run func() Object
+	switch fn := args[0].(type) {
+	case *Function:
+		taskScope := NewScope(fn.Scope)
+		taskScope.rt = rt
+		run = func() Object { return applyFunctionIn(taskScope, fn, args[1:]...) }
+	case *Builtin:
+		taskScope := NewScope(scope)
+		taskScope.rt = rt
+		run = func() Object { return fn.call(taskScope, args[1:]...) }
+	default:
+		return newError(INPUTERROR, args[0].Type(), "spawn")
+	}
+	if err := scope.runtime().startTask(); err != nil {
+		return err
+	}
+	go func() {
+		defer close(task.done)
+		defer rt.endTask()
+		defer func() {
+			if r := recover(); r != nil {
+				task.result = newError(CONCURRENCYERROR, fmt.Sprintf("task panicked: %v", r))
+			}
+		}()
+		task.result = run()
+	}()
+	return task
+}
+
+// Channel wraps a Go channel of Objects.
+type Channel struct {
+	ch chan Object
+}
+
+func newChannel(args ...Object) Object {
+	if len(args) > 1 {
+		return newError(ARGUMENTERROR, "0 or 1", len(args))
+	}
+	capacity := 0
+	if len(args) == 1 {
+		i, ok := args[0].(*Integer)
+		if !ok || i.Value < 0 {
+			return newError(INPUTERROR, args[0].Inspect(), "chan")
+		}
+		capacity = int(i.Value)
+	}
+	return &Channel{ch: make(chan Object, capacity)}
+}
+
+func (c *Channel) Inspect() string  { return fmt.Sprintf("chan(%d)", cap(c.ch)) }
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) CallMethod(method string, args ...Object) Object {
+	return c.call(nil, method, args...)
+}
+
+func (c *Channel) call(scope *Scope, method string, args ...Object) Object {
+	switch method {
+	case "send":
+		if len(args) != 1 {
+			return newError(ARGUMENTERROR, "1", len(args))
+		}
+		return c.send(scope, args[0])
+	case "recv":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return c.recv(scope)
+	case "close":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return c.close()
+	case "len":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return &Integer{Value: int64(len(c.ch))}
+	case "cap":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return &Integer{Value: int64(cap(c.ch))}
+	}
+	return newError(NOMETHODERROR, method, c.Type())
+}
+
+// send blocks until value is received or buffered. Sending on a closed
+// channel is an error rather than a panic.
+func (c *Channel) send(scope *Scope, value Object) (result Object) {
+	defer func() {
+		if recover() != nil {
+			result = newError(CONCURRENCYERROR, "send on closed channel")
+		}
+	}()
+	select {
+	case c.ch <- value:
+		return NULL
+	case <-scope.runtime().done():
+		return scope.runtime().interrupted()
+	}
+}
+
+// recv blocks until a value is available. A closed, drained channel returns
+// null.
+func (c *Channel) recv(scope *Scope) Object {
+	select {
+	case v, ok := <-c.ch:
+		if !ok {
+			return NULL
+		}
+		return v
+	case <-scope.runtime().done():
+		return scope.runtime().interrupted()
+	}
+}
+
+func (c *Channel) close() (result Object) {
+	defer func() {
+		if recover() != nil {
+			result = newError(CONCURRENCYERROR, "close of closed channel")
+		}
+	}()
+	close(c.ch)
+	return NULL
+}
+
+// Mutex is a lock for values shared between tasks. It is built on a channel
+// so that lock() can be interrupted and unlocking an unlocked mutex is an
+// error instead of a fatal runtime error.
+type Mutex struct {
+	ch chan struct{}
+}
+
+func (m *Mutex) Inspect() string  { return "mutex" }
+func (m *Mutex) Type() ObjectType { return MUTEX_OBJ }
+func (m *Mutex) CallMethod(method string, args ...Object) Object {
+	return m.call(nil, method, args...)
+}
+
+func (m *Mutex) call(scope *Scope, method string, args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	switch method {
+	case "lock":
+		select {
+		case m.ch <- struct{}{}:
+			return NULL
+		case <-scope.runtime().done():
+			return scope.runtime().interrupted()
+		}
+	case "try_lock":
+		select {
+		case m.ch <- struct{}{}:
+			return TRUE
+		default:
+			return FALSE
+		}
+	case "unlock":
+		select {
+		case <-m.ch:
+			return NULL
+		default:
+			return newError(CONCURRENCYERROR, "unlock of unlocked mutex")
+		}
+	}
+	return newError(NOMETHODERROR, method, m.Type())
+}
+
+// WaitGroup waits for a number of tasks to call done().
+type WaitGroup struct {
+	mu    sync.Mutex
+	count int64
+	// zero is closed whenever count is zero
+	zero chan struct{}
+}
+
+func newWaitGroup() *WaitGroup {
+	wg := &WaitGroup{zero: make(chan struct{})}
+	close(wg.zero)
+	return wg
+}
+
+func (wg *WaitGroup) Inspect() string  { return "waitgroup" }
+func (wg *WaitGroup) Type() ObjectType { return WAITGROUP_OBJ }
+func (wg *WaitGroup) CallMethod(method string, args ...Object) Object {
+	return wg.call(nil, method, args...)
+}
+
+func (wg *WaitGroup) call(scope *Scope, method string, args ...Object) Object {
+	switch method {
+	case "add":
+		if len(args) != 1 {
+			return newError(ARGUMENTERROR, "1", len(args))
+		}
+		n, ok := args[0].(*Integer)
+		if !ok {
+			return newError(INPUTERROR, args[0].Type(), "add")
+		}
+		return wg.add(n.Value)
+	case "done":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		return wg.add(-1)
+	case "wait":
+		if len(args) != 0 {
+			return newError(ARGUMENTERROR, "0", len(args))
+		}
+		wg.mu.Lock()
+		zero := wg.zero
+		wg.mu.Unlock()
+		select {
+		case <-zero:
+			return NULL
+		case <-scope.runtime().done():
+			return scope.runtime().interrupted()
+		}
+	}
+	return newError(NOMETHODERROR, method, wg.Type())
+}
+
+func (wg *WaitGroup) add(n int64) Object {
+	wg.mu.Lock()
+	defer wg.mu.Unlock()
+	if wg.count+n < 0 {
+		return newError(CONCURRENCYERROR, "negative waitgroup counter")
+	}
+	if wg.count == 0 && n > 0 {
+		wg.zero = make(chan struct{})
+	}
+	wg.count += n
+	if wg.count == 0 && n < 0 {
+		close(wg.zero)
+	}
+	return NULL
+}
+
+// evalSelectExpression evaluates the channels and values of every case, then
+// runs the body of the first case that can proceed.
+func evalSelectExpression(se *ast.SelectExpression, s *Scope) Object {
+	cases := []reflect.SelectCase{}
+	for _, c := range se.Cases {
+		obj := Eval(c.Call.Object, s)
+		if obj.Type() == ERROR_OBJ {
+			return obj
+		}
+		ch, ok := obj.(*Channel)
+		if !ok {
+			return newError(INPUTERROR, obj.Type(), "select")
+		}
+		sc := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.ch)}
+		if c.IsSend() {
+			value := Eval(c.Call.Call.(*ast.CallExpression).Arguments[0], s)
+			if value.Type() == ERROR_OBJ {
+				return value
+			}
+			sc.Dir = reflect.SelectSend
+			sc.Send = reflect.ValueOf(&value).Elem()
+		}
+		cases = append(cases, sc)
+	}
+	if se.Default != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	} else if done := s.rt.done(); done != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+	}
+	if len(cases) == 0 {
+		return newError(CONCURRENCYERROR, "select with no cases blocks forever")
+	}
+
+	chosen, value, ok, err := selectChannels(cases)
+	if err != nil {
+		return err
+	}
+	switch {
+	case chosen < len(se.Cases):
+		c := se.Cases[chosen]
+		scope := NewScope(s)
+		if c.Binding != nil {
+			var received Object = NULL
+			if ok {
+				received = value.Interface().(Object)
+			}
+			scope.Set(c.Binding.Value, received)
+		}
+		return Eval(c.Body, scope)
+	case se.Default != nil:
+		return Eval(se.Default, NewScope(s))
+	}
+	return s.rt.interrupted()
+}
+
+func selectChannels(cases []reflect.SelectCase) (chosen int, value reflect.Value, ok bool, err Object) {
+	defer func() {
+		if recover() != nil {
+			err = newError(CONCURRENCYERROR, "send on closed channel")
+		}
+	}()
+	chosen, value, ok = reflect.Select(cases)
+	return
+}
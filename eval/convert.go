@@ -0,0 +1,305 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var (
+	goObjectType = reflect.TypeOf((*Object)(nil)).Elem()
+	goErrorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value into an Object. Integers, strings, booleans,
+// nil, slices, arrays and maps are supported, as are values that already are
+// Objects. Map keys must convert to hashable Objects.
+func ToObject(v interface{}) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+	if o, ok := v.(Object); ok {
+		return o, nil
+	}
+	return valueToObject(reflect.ValueOf(v))
+}
+
+func valueToObject(v reflect.Value) (Object, error) {
+	if v.IsValid() && v.Type().Implements(goObjectType) {
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return NULL, nil
+	case reflect.Bool:
+		return nativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return valueToObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL, nil
+		}
+		arr := &Array{Members: []Object{}}
+		for i := 0; i < v.Len(); i++ {
+			m, err := valueToObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			arr.Members = append(arr.Members, m)
+		}
+		return arr, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		// sort the keys so that the hash order does not depend on map
+		// iteration order
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for _, k := range keys {
+			key, err := valueToObject(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(Hashable); !ok {
+				return nil, fmt.Errorf("cannot use %s as a hash key", key.Type())
+			}
+			value, err := valueToObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			hash.Push(key, value)
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+}
+
+// FromObject converts an Object into a plain Go value: INTEGER to int64,
+// STRING to string, BOOLEAN to bool, NULL to nil, ARRAY and SET to
+// []interface{}, and HASH and STRUCT to map[string]interface{}. Other objects
+// are returned unchanged.
+func FromObject(o Object) interface{} {
+	switch o := o.(type) {
+	case nil, *Null:
+		return nil
+	case *Integer:
+		return o.Value
+	case *String:
+		return o.Value
+	case *InterpolatedString:
+		return o.Inspect()
+	case *Boolean:
+		return o.Value
+	case *Array:
+		return fromObjects(o.Members)
+	case *Set:
+		return fromObjects(o.Elements())
+	case *Hash:
+		m := make(map[string]interface{})
+		for _, pair := range o.OrderedPairs() {
+			m[pair.Key.Inspect()] = FromObject(pair.Value)
+		}
+		return m
+	case *Struct:
+		m := make(map[string]interface{})
+		for name, value := range o.fieldValues() {
+			m[name] = FromObject(value)
+		}
+		return m
+	}
+	return o
+}
+
+func fromObjects(objs []Object) []interface{} {
+	values := make([]interface{}, len(objs))
+	for i, o := range objs {
+		values[i] = FromObject(o)
+	}
+	return values
+}
+
+// objectToValue converts o to a Go value assignable to t, for passing script
+// arguments to registered Go functions.
+func objectToValue(o Object, t reflect.Type) (reflect.Value, error) {
+	if t.Implements(goObjectType) || t == goObjectType {
+		if reflect.TypeOf(o).AssignableTo(t) {
+			return reflect.ValueOf(o), nil
+		}
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", o.Type(), t)
+	}
+	if t.Kind() == reflect.Interface {
+		v := FromObject(o)
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(v), nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := o.(*Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := o.(*Integer); ok {
+			v := reflect.New(t).Elem()
+			if v.OverflowInt(i.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetInt(i.Value)
+			return v, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, ok := o.(*Integer); ok {
+			v := reflect.New(t).Elem()
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetUint(uint64(i.Value))
+			return v, nil
+		}
+	case reflect.String:
+		switch s := o.(type) {
+		case *String:
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		case *InterpolatedString:
+			return reflect.ValueOf(s.Inspect()).Convert(t), nil
+		}
+	case reflect.Slice:
+		var members []Object
+		switch a := o.(type) {
+		case *Array:
+			members = a.Members
+		case *Set:
+			members = a.Elements()
+		case *Null:
+			return reflect.Zero(t), nil
+		default:
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s", o.Type(), t)
+		}
+		slice := reflect.MakeSlice(t, len(members), len(members))
+		for i, m := range members {
+			v, err := objectToValue(m, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(v)
+		}
+		return slice, nil
+	case reflect.Map:
+		h, ok := o.(*Hash)
+		if !ok {
+			if o == NULL {
+				return reflect.Zero(t), nil
+			}
+			break
+		}
+		m := reflect.MakeMap(t)
+		for _, pair := range h.OrderedPairs() {
+			k, err := objectToValue(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, err := objectToValue(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(k, v)
+		}
+		return m, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", o.Type(), t)
+}
+
+// NewGoFunction wraps a Go function so it can be called from scripts.
+// Arguments are converted from Objects to the function's parameter types and
+// the result with ToObject. The function may return nothing, a value, an
+// error, or a value and an error; a non-nil error becomes a script error.
+func NewGoFunction(name string, fn interface{}) (*Builtin, error) {
+	if f, ok := fn.(func(scope *Scope, args ...Object) Object); ok {
+		return &Builtin{Fn: f, Name: name, Arity: Variadic}, nil
+	}
+	if f, ok := fn.(BuiltinFunc); ok {
+		return &Builtin{Fn: f, Name: name, Arity: Variadic}, nil
+	}
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s: expected a function, got %s", name, t)
+	}
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != goErrorType:
+		return nil, fmt.Errorf("%s: function must return at most a value and an error", name)
+	}
+	arity := t.NumIn()
+	if t.IsVariadic() {
+		arity = Variadic
+	}
+	return &Builtin{Name: name, Arity: arity, Fn: func(scope *Scope, args ...Object) Object {
+		in, err := goArguments(name, t, args)
+		if err != nil {
+			return err
+		}
+		out := v.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == goErrorType {
+			if e := out[len(out)-1]; !e.IsNil() {
+				return &Error{Message: e.Interface().(error).Error()}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return NULL
+		}
+		result, cerr := valueToObject(out[0])
+		if cerr != nil {
+			return newError(CONVERSIONERROR, cerr.Error())
+		}
+		return result
+	}}, nil
+}
+
+func goArguments(name string, t reflect.Type, args []Object) ([]reflect.Value, Object) {
+	n := t.NumIn()
+	if t.IsVariadic() {
+		if len(args) < n-1 {
+			return nil, newError(ARGUMENTERROR, fmt.Sprintf("at least %d", n-1), len(args))
+		}
+	} else if len(args) != n {
+		return nil, newError(ARGUMENTERROR, fmt.Sprint(n), len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var pt reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			pt = t.In(n - 1).Elem()
+		} else {
+			pt = t.In(i)
+		}
+		v, err := objectToValue(arg, pt)
+		if err != nil {
+			return nil, newError(CONVERSIONERROR, fmt.Sprintf("argument %d to %s: %s", i+1, name, err))
+		}
+		in[i] = v
+	}
+	return in, nil
+}
@@ -18,32 +18,92 @@ const (
 	RTERROR
 	CONSTRUCTERR
 	INLENERR
+	FORMATERROR
+	REGEXERROR
+	JSONERROR
+	IMPORTERROR
+	MODULEERROR
+	NOTCALLABLE
+	CONVERSIONERROR
+	NODOCERROR
+	STEPLIMITERROR
+	TIMEOUTERROR
+	RECURSIONERROR
+	SIZELIMITERROR
+	TASKLIMITERROR
+	PERMISSIONERROR
+	CONCURRENCYERROR
+	STRUCTERROR
+	ASSERTERROR
+	INTERNALERROR
 )
 
 var errorType = map[int]string{
-	PREFIXOP:      "unsupported operator for prefix expression:'%s' and type: %s",
-	INFIXOP:       "unsupported operator for infix expression: '%s' and types %s and %s",
-	UNKNOWNIDENT:  "unknown identifier: '%s' is not defined",
-	NOMETHODERROR: "undefined method '%s' for object %s",
-	NOINDEXERROR:  "index error: type %s is not indexable",
-	KEYERROR:      "key error: type %s is not hashable",
-	INDEXERROR:    "index error: '%d' out of range",
-	SLICEERROR:    "index error: slice '%d:%d' out of range",
-	ARGUMENTERROR: "wrong number of arguments. expected=%s, got=%d",
-	INPUTERROR:    "unsupported input type '%s' for function or method: %s",
-	RTERROR:       "return type should be %s",
-	CONSTRUCTERR:  "%s argument for addm should be type %s. got=%s",
-	INLENERR:      "function %s takes input with max length %s. got=%s",
+	PREFIXOP:         "unsupported operator for prefix expression:'%s' and type: %s",
+	INFIXOP:          "unsupported operator for infix expression: '%s' and types %s and %s",
+	UNKNOWNIDENT:     "unknown identifier: '%s' is not defined",
+	NOMETHODERROR:    "undefined method '%s' for object %s",
+	NOINDEXERROR:     "index error: type %s is not indexable",
+	KEYERROR:         "key error: type %s is not hashable",
+	INDEXERROR:       "index error: '%d' out of range",
+	SLICEERROR:       "index error: slice '%d:%d' out of range",
+	ARGUMENTERROR:    "wrong number of arguments. expected=%s, got=%d",
+	INPUTERROR:       "unsupported input type '%s' for function or method: %s",
+	RTERROR:          "return type should be %s",
+	CONSTRUCTERR:     "%s argument for addm should be type %s. got=%s",
+	INLENERR:         "function %s takes input with max length %s. got=%s",
+	FORMATERROR:      "format error: %s",
+	REGEXERROR:       "regex error: %s",
+	JSONERROR:        "json error: %s",
+	IMPORTERROR:      "import error: '%s' is not exported by module %s",
+	MODULEERROR:      "module error: %s",
+	NOTCALLABLE:      "type error: %s is not callable",
+	CONVERSIONERROR:  "conversion error: %s",
+	NODOCERROR:       "help: no documentation for '%s'",
+	STEPLIMITERROR:   "step limit error: exceeded %d steps",
+	TIMEOUTERROR:     "timeout error: %s",
+	RECURSIONERROR:   "recursion error: maximum call depth of %d exceeded",
+	SIZELIMITERROR:   "size limit error: %s of size %d exceeds the maximum of %d",
+	TASKLIMITERROR:   "task limit error: more than %d tasks running",
+	PERMISSIONERROR:  "permission error: %s",
+	CONCURRENCYERROR: "concurrency error: %s",
+	STRUCTERROR:      "struct error: %s",
+	ASSERTERROR:      "assertion error: %s",
+	INTERNALERROR:    "internal error: %v",
 }
 
 func newError(t int, args ...interface{}) Object {
 	return &Error{Message: fmt.Sprintf(errorType[t], args...)}
 }
 
-type Error struct{ Message string }
+type Error struct {
+	Message string
+	// exit is set on the error raised by exit(), which stops the program
+	// with status code
+	exit bool
+	code int
+}
+
+// ExitError is returned by an Interpreter when the program calls exit().
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+// hostError converts an error object for returning to Go code.
+func hostError(e *Error) error {
+	if e.exit {
+		return &ExitError{Code: e.code}
+	}
+	return e
+}
 
 func (e *Error) Inspect() string  { return "Err: " + e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
+
+// Error lets an *Error be returned to Go code as an error.
+func (e *Error) Error() string { return e.Message }
 func (e *Error) CallMethod(method string, args ...Object) Object {
 	return newError(NOMETHODERROR, method, e.Type())
 }
@@ -2,7 +2,7 @@ package eval
 
 import (
 	"monkey/ast"
-	"os"
+	"strconv"
 )
 
 var (
@@ -12,9 +12,25 @@ var (
 	BREAK = &Break{}
 )
 
-var includeScope *Scope
-
+// Eval evaluates node in scope, counting the step against the scope's limits.
+// Once a limit has been exceeded every call returns that error, so evaluation
+// unwinds regardless of how intermediate errors are handled.
 func Eval(node ast.Node, scope *Scope) Object {
+	rt := scope.rt
+	if err := rt.step(); err != nil {
+		return err
+	}
+	result := evalNode(node, scope)
+	if err := rt.failed(); err != nil {
+		return err
+	}
+	if err := rt.checkSize(result); err != nil {
+		return err
+	}
+	return result
+}
+
+func evalNode(node ast.Node, scope *Scope) Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, scope)
@@ -22,6 +38,10 @@ func Eval(node ast.Node, scope *Scope) Object {
 		return Eval(node.Expression, scope)
 	case *ast.IncludeStatement:
 		return evalIncludeStatement(node, scope)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, scope)
+	case *ast.ExportStatement:
+		return evalExportStatement(node, scope)
 	case *ast.LetStatement:
 		return evalLetStatement(node, scope)
 	case *ast.ReturnStatement:
@@ -40,8 +60,12 @@ func Eval(node ast.Node, scope *Scope) Object {
 		return evalArrayLiteral(node, scope)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, scope)
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, scope)
 	case *ast.StructLiteral:
 		return evalStructLiteral(node, scope)
+	case *ast.StructStatement:
+		return evalStructStatement(node, scope)
 	case *ast.FunctionLiteral:
 		return evalFunctionLiteral(node, scope)
 	case *ast.PrefixExpression:
@@ -60,6 +84,8 @@ func Eval(node ast.Node, scope *Scope) Object {
 		return evalIndexExpression(node, scope)
 	case *ast.DoLoop:
 		return evalDoLoopExpression(node, scope)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, scope)
 	case *ast.BreakExpression:
 		return BREAK
 	case *ast.AssignExpression:
@@ -71,7 +97,6 @@ func Eval(node ast.Node, scope *Scope) Object {
 // Program Evaluation Entry Point Functions, and Helpers:
 
 func evalProgram(program *ast.Program, scope *Scope) (results Object) {
-	loadIncludes(program.Includes, scope)
 	for _, statement := range program.Statements {
 		results = Eval(statement, scope)
 		switch s := results.(type) {
@@ -87,36 +112,55 @@ func evalProgram(program *ast.Program, scope *Scope) (results Object) {
 	return results
 }
 
-func loadIncludes(includes map[string]*ast.IncludeStatement, s *Scope) {
-	if includeScope == nil {
-		includeScope = NewScope(nil)
+// Statements...
+
+// Including a directory module splices its module.my into the current scope,
+// while including a file binds an IncludedObject with its own scope under the
+// include path.
+func evalIncludeStatement(i *ast.IncludeStatement, s *Scope) Object {
+	name := i.IncludePath.String()
+
+	imported, err := loadModule(name, name, i.Dir, i.IncludePaths, s, s.IncludeWriter(), true)
+	if err != nil {
+		return err
 	}
-	for _, p := range includes {
-		Eval(p, s)
+	if imported == nil {
+		return NULL
 	}
+	return s.Set(name, imported)
 }
 
-// Statements...
-func evalIncludeStatement(i *ast.IncludeStatement, s *Scope) Object {
-	if i.IsModule {
-		return evalProgram(i.Program, s)
+// Imports always evaluate the module in its own scope. `import` binds the
+// module object, `from ... import` binds the requested exported names.
+func evalImportStatement(i *ast.ImportStatement, s *Scope) Object {
+	module, err := loadModule(i.Path, i.Path, i.Dir, i.IncludePaths, s, s.Writer(), false)
+	if err != nil {
+		return err
 	}
-	imported := &IncludedObject{Name: i.IncludePath.String(), Scope: NewScope(nil)}
-
-	// capture stdout to suppress output during evaluating import
-	so := os.Stdout
-	_, w, _ := os.Pipe()
-	os.Stdout = w
-	if _, ok := includeScope.Get(i.IncludePath.String()); !ok {
-		evalProgram(i.Program, imported.Scope)
-		includeScope.Set(i.IncludePath.String(), imported)
+	if len(i.Names) == 0 {
+		return s.Set(i.Binding(), module)
 	}
+	for _, name := range i.Names {
+		val, ok := module.Get(name.Value)
+		if !ok {
+			return newError(IMPORTERROR, name.Value, i.Path)
+		}
+		s.Set(name.Value, val)
+	}
+	return NULL
+}
 
-	// restore stdout
-	w.Close()
-	os.Stdout = so
-
-	return imported
+func evalExportStatement(e *ast.ExportStatement, s *Scope) Object {
+	var val Object = NULL
+	if e.Statement != nil {
+		if val = Eval(e.Statement, s); val.Type() == ERROR_OBJ {
+			return val
+		}
+	}
+	for _, name := range e.Exported() {
+		s.Export(name)
+	}
+	return val
 }
 
 func evalLetStatement(l *ast.LetStatement, scope *Scope) (val Object) {
@@ -127,6 +171,9 @@ func evalLetStatement(l *ast.LetStatement, scope *Scope) (val Object) {
 }
 
 func evalAssignStatement(a *ast.AssignExpression, scope *Scope) (val Object) {
+	if a.Object != nil {
+		return evalFieldAssignment(a, scope)
+	}
 	if val = Eval(a.Value, scope); val.Type() != ERROR_OBJ {
 		v, ok := scope.Reset(a.Name.String(), val)
 		if ok {
@@ -137,6 +184,22 @@ func evalAssignStatement(a *ast.AssignExpression, scope *Scope) (val Object) {
 	return
 }
 
+func evalFieldAssignment(a *ast.AssignExpression, scope *Scope) Object {
+	obj := Eval(a.Object, scope)
+	if obj.Type() == ERROR_OBJ {
+		return obj
+	}
+	st, ok := obj.(*Struct)
+	if !ok {
+		return newError(STRUCTERROR, "can't assign field '"+a.Name.Value+"' of "+typeName(obj))
+	}
+	val := Eval(a.Value, scope)
+	if val.Type() == ERROR_OBJ {
+		return val
+	}
+	return st.setField(a.Name.Value, val)
+}
+
 func evalReturnStatment(r *ast.ReturnStatement, scope *Scope) Object {
 	if value := Eval(r.ReturnValue, scope); value != nil {
 		return &ReturnValue{Value: value}
@@ -162,8 +225,8 @@ func evalStringLiteral(s *ast.StringLiteral) Object {
 }
 
 func evalInterpolatedString(is *ast.InterpolatedString, scope *Scope) Object {
-	s := &InterpolatedString{Value: &String{}, RawValue: is.Value, Expressions: is.ExprMap}
-	s.Interpolate(scope)
+	s := &InterpolatedString{RawValue: is.Value, Expressions: is.ExprMap}
+	s.Value = s.Interpolate(scope)
 	return s
 }
 
@@ -175,28 +238,42 @@ func evalArrayLiteral(a *ast.ArrayLiteral, scope *Scope) Object {
 func evalIdentifier(i *ast.Identifier, scope *Scope) Object {
 	val, ok := scope.Get(i.String())
 	if !ok {
-		if val, ok = includeScope.Get(i.String()); !ok {
-			return newError(UNKNOWNIDENT, i.String())
+		if m, ok := builtinModules[i.String()]; ok {
+			return m
 		}
+		return newError(UNKNOWNIDENT, i.String())
 	}
 	if i, ok := val.(*InterpolatedString); ok {
-		i.Interpolate(scope)
-		return i
+		return i.Interpolate(scope)
 	}
 	return val
 }
 
 func evalHashLiteral(hl *ast.HashLiteral, scope *Scope) Object {
-	hashMap := make(map[HashKey]HashPair)
-	for key, value := range hl.Pairs {
-		key := Eval(key, scope)
-		if hashable, ok := key.(Hashable); ok {
-			hashMap[hashable.HashKey()] = HashPair{Key: key, Value: Eval(value, scope)}
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	for _, node := range hl.Order {
+		key := Eval(node, scope)
+		if _, ok := key.(Hashable); ok {
+			hash.Push(key, Eval(hl.Pairs[node], scope))
 		} else {
 			return newError(KEYERROR, key.Type())
 		}
 	}
-	return &Hash{Pairs: hashMap}
+	return hash
+}
+
+func evalSetLiteral(sl *ast.SetLiteral, scope *Scope) Object {
+	members := evalArgs(sl.Members, scope)
+	for _, m := range members {
+		if m.Type() == ERROR_OBJ {
+			return m
+		}
+	}
+	set, err := NewSet(members...)
+	if err != nil {
+		return err
+	}
+	return set
 }
 
 func evalStructLiteral(s *ast.StructLiteral, scope *Scope) Object {
@@ -226,11 +303,13 @@ func evalPrefixExpression(p *ast.PrefixExpression, s *Scope) Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		if i, ok := right.(*Integer); ok {
-			i.Value = -i.Value
-			return i
+			return &Integer{Value: -i.Value}
+		}
+		if st, ok := protocolMethod(right, "__neg__"); ok {
+			return st.CallMethod("__neg__")
 		}
 	}
-	return newError(PREFIXOP, p.Operator, right.Type())
+	return newError(PREFIXOP, p.Operator, typeName(right))
 }
 
 // Helper for evaluating Bang(!) expressions. Coerces truthyness based on object presence.
@@ -256,22 +335,38 @@ func evalInfixExpression(i *ast.InfixExpression, s *Scope) Object {
 	} else if right.Type() == ERROR_OBJ {
 		return right
 	}
+	return evalInfix(s, i.Operator, left, right)
+}
+
+// evalInfix applies operator to operands that have already been evaluated.
+// The results that may be large are checked against the limits of scope,
+// which may be nil.
+func evalInfix(scope *Scope, operator string, left, right Object) Object {
+	if result, ok := evalOperatorMethod(operator, left, right); ok {
+		return result
+	}
 
 	switch {
-	case i.Operator == "and":
+	case operator == "and":
 		return nativeBoolToBooleanObject(objectToNativeBoolean(left) && objectToNativeBoolean(right))
-	case i.Operator == "or":
+	case operator == "or":
 		return nativeBoolToBooleanObject(objectToNativeBoolean(left) || objectToNativeBoolean(right))
 	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
-		return evalIntInfixExpression(i.Operator, left, right)
+		return evalIntInfixExpression(operator, left, right)
 	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
-		return evalStringInfixExpression(i.Operator, left, right)
-	case i.Operator == "==":
+		return evalStringInfixExpression(scope, operator, left, right)
+	case operator == "*" && left.Type() == STRING_OBJ && right.Type() == INTEGER_OBJ:
+		return callMethod(scope, left, "repeat", right)
+	case operator == "*" && left.Type() == INTEGER_OBJ && right.Type() == STRING_OBJ:
+		return callMethod(scope, right, "repeat", left)
+	case left.Type() == SET_OBJ && right.Type() == SET_OBJ:
+		return evalSetInfixExpression(operator, left, right)
+	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
-	case i.Operator == "!=":
+	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	}
-	return newError(INFIXOP, i.Operator, left.Type(), right.Type())
+	return newError(INFIXOP, operator, typeName(left), typeName(right))
 }
 
 func objectToNativeBoolean(o Object) bool {
@@ -325,7 +420,7 @@ func evalModuloExpression(left *Integer, right *Integer) Object {
 	return &Integer{Value: mod}
 }
 
-func evalStringInfixExpression(operator string, left Object, right Object) Object {
+func evalStringInfixExpression(scope *Scope, operator string, left Object, right Object) Object {
 	l := left.(*String)
 	r := right.(*String)
 
@@ -335,6 +430,9 @@ func evalStringInfixExpression(operator string, left Object, right Object) Objec
 	case "!=":
 		return nativeBoolToBooleanObject(l.Value != r.Value)
 	case "+":
+		if err := scope.runtime().checkLength(STRING_OBJ, int64(len(l.Value))+int64(len(r.Value))); err != nil {
+			return err
+		}
 		return &String{Value: l.Value + r.Value}
 	}
 	return newError(INFIXOP, operator, l.Type(), r.Type())
@@ -359,6 +457,9 @@ func evalDoLoopExpression(dl *ast.DoLoop, s *Scope) Object {
 		if _, ok := e.(*Break); ok {
 			break
 		}
+		if err, ok := e.(*Error); ok {
+			return err
+		}
 		if v, ok := e.(*ReturnValue); ok {
 			if v.Value != nil {
 				return v.Value
@@ -385,11 +486,12 @@ func isTrue(obj Object) bool {
 
 // Block Statement Evaluation - The innards of both IF and Function calls
 // very similar to parseProgram, but because we need to leave the return
-// value wrapped in it's Object, it remains, for now.
+// value wrapped in it's Object, it remains, for now. Like a return, an error
+// ends the block and becomes its value.
 func evalBlockStatements(block []ast.Statement, scope *Scope) (results Object) {
 	for _, statement := range block {
 		results = Eval(statement, scope)
-		if results != nil && results.Type() == RETURN_VALUE_OBJ {
+		if results != nil && (results.Type() == RETURN_VALUE_OBJ || results.Type() == ERROR_OBJ) {
 			return
 		}
 		if _, ok := results.(*Break); ok {
@@ -407,22 +509,89 @@ func evalFunctionCall(call *ast.CallExpression, s *Scope) Object {
 			fn = &Function{Literal: f, Scope: s}
 			s.Set(call.Function.String(), fn)
 		} else if builtin, ok := builtins[call.Function.String()]; ok {
-			return builtin.Fn(evalArgs(call.Arguments, s)...)
+			return callBuiltin(builtin, call, s)
 		} else {
 			return newError(UNKNOWNIDENT, call.Function.String())
 		}
 	}
-	f := fn.(*Function)
-	f.Scope = NewScope(s)
-	args := evalArgs(call.Arguments, f.Scope)
-	// TODO: If not enough of arguments are passed a panic occur, if too few, no warning or error
+	switch callee := fn.(type) {
+	case *Builtin:
+		return callBuiltin(callee, call, s)
+	case *StructType:
+		args := evalArgs(call.Arguments, s)
+		for _, a := range args {
+			if a.Type() == ERROR_OBJ {
+				return a
+			}
+		}
+		return callee.construct(args)
+	}
+	f, ok := fn.(*Function)
+	if !ok {
+		return newError(NOTCALLABLE, typeName(fn))
+	}
+	// the call scope is local so that tasks can call the same function
+	// concurrently
+	scope := NewScope(s)
+	args := evalArgs(call.Arguments, scope)
+	if len(args) != len(f.Literal.Parameters) {
+		return newError(ARGUMENTERROR, strconv.Itoa(len(f.Literal.Parameters)), len(args))
+	}
+	if err := s.rt.enter(); err != nil {
+		return err
+	}
+	defer s.rt.leave()
+	for i, v := range f.Literal.Parameters {
+		scope.Set(v.String(), args[i])
+	}
+	r := Eval(f.Literal.Body, scope)
+	if obj, ok := r.(*ReturnValue); ok {
+		return obj.Value
+	}
+	return r
+}
+
+// callBuiltin calls b with the evaluated arguments of call. assert is given
+// the call itself, so that a failure can show the expression that failed.
+func callBuiltin(b *Builtin, call *ast.CallExpression, s *Scope) Object {
+	if b == builtins["assert"] {
+		return evalAssert(call, s)
+	}
+	args := evalArgs(call.Arguments, s)
+	for _, a := range args {
+		if a.Type() == ERROR_OBJ {
+			return a
+		}
+	}
+	return b.call(s, args...)
+}
+
+// applyFunction calls a Function object from Go code, e.g. a callback passed
+// to a builtin method, binding args to its parameters in a new enclosed scope.
+func applyFunction(f *Function, args ...Object) Object {
+	return applyFunctionIn(NewScope(f.Scope), f, args...)
+}
+
+// applyFunctionIn is applyFunction with the caller providing the new scope,
+// e.g. one running on a spawned task's runtime.
+func applyFunctionIn(scope *Scope, f *Function, args ...Object) Object {
+	if len(args) != len(f.Literal.Parameters) {
+		return newError(ARGUMENTERROR, strconv.Itoa(len(f.Literal.Parameters)), len(args))
+	}
+	if err := scope.rt.enter(); err != nil {
+		return err
+	}
+	defer scope.rt.leave()
 	for i, v := range f.Literal.Parameters {
-		f.Scope.Set(v.String(), args[i])
+		scope.Set(v.String(), args[i])
 	}
-	r := Eval(f.Literal.Body, f.Scope)
+	r := Eval(f.Literal.Body, scope)
 	if obj, ok := r.(*ReturnValue); ok {
 		return obj.Value
 	}
+	if r == nil {
+		return NULL
+	}
 	return r
 }
 
@@ -433,14 +602,26 @@ func evalMethodCallExpression(call *ast.MethodCallExpression, scope *Scope) Obje
 	case *IncludedObject:
 		switch o := call.Call.(type) {
 		case *ast.Identifier:
-			if i, ok := m.Scope.Get(call.Call.String()); ok {
+			if i, ok := m.Get(call.Call.String()); ok {
 				return i
 			}
 		case *ast.CallExpression:
 			if o.Function.String() == "Scope" {
 				return obj.CallMethod("Scope")
 			}
-			return evalFunctionCall(o, m.Scope)
+			if f, ok := m.Get(o.Function.String()); ok {
+				fn, ok := f.(*Function)
+				if !ok {
+					return newError(NOMETHODERROR, o.Function.String(), typeName(obj))
+				}
+				args := evalArgs(o.Arguments, scope)
+				for _, a := range args {
+					if a.Type() == ERROR_OBJ {
+						return a
+					}
+				}
+				return applyFunction(fn, args...)
+			}
 		}
 	case *Struct:
 		switch o := call.Call.(type) {
@@ -450,15 +631,15 @@ func evalMethodCallExpression(call *ast.MethodCallExpression, scope *Scope) Obje
 			}
 		case *ast.CallExpression:
 			args := evalArgs(o.Arguments, scope)
-			return obj.CallMethod(o.Function.String(), args...)
+			return callMethod(scope, obj, o.Function.String(), args...)
 		}
 	default:
 		if method, ok := call.Call.(*ast.CallExpression); ok {
 			args := evalArgs(method.Arguments, scope)
-			return obj.CallMethod(method.Function.String(), args...)
+			return callMethod(scope, obj, method.Function.String(), args...)
 		}
 	}
-	return newError(NOMETHODERROR, call.String(), obj.Type())
+	return newError(NOMETHODERROR, call.String(), typeName(obj))
 
 }
 
@@ -483,8 +664,16 @@ func evalIndexExpression(ie *ast.IndexExpression, s *Scope) Object {
 		return evalHashKeyIndex(iterable, ie, s)
 	case *String:
 		return evalStringIndex(iterable, ie, s)
+	case *Struct:
+		if iterable.hasMethod("__index__") {
+			index := Eval(ie.Index, s)
+			if index.Type() == ERROR_OBJ {
+				return index
+			}
+			return iterable.CallMethod("__index__", index)
+		}
 	}
-	return newError(NOINDEXERROR, left.Type())
+	return newError(NOINDEXERROR, typeName(left))
 }
 
 func evalStringIndex(str *String, ie *ast.IndexExpression, s *Scope) Object {
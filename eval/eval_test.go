@@ -1,11 +1,21 @@
 package eval
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/ioutil"
+	"math"
 	"monkey/lexer"
 	"monkey/parser"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDoLoop(t *testing.T) {
@@ -75,7 +85,8 @@ func TestStructObjects(t *testing.T) {
 		{`struct (a->15).a`, 15},
 		{`let st = struct (a->15); type(addm(st, "get", fn() { self.a })) == "NULL"`, true},
 		{`let st = struct (a->15); addm(st, "get", fn() { self.a }); st.get()`, 15},
-		{`let st = struct (a->15); addm(st, "get", fn() { a }); type(st.get()) == "ERROR"`, true},
+		// errors in the arguments of builtins are returned without calling them
+		{`let st = struct (a->15); addm(st, "get", fn() { a }); type(st.get()) == "ERROR"`, newError(UNKNOWNIDENT, "a")},
 	}
 
 	for _, tt := range tests {
@@ -85,12 +96,247 @@ func TestStructObjects(t *testing.T) {
 			testIntegerObject(t, evaluated, int64(expected))
 		case bool:
 			testBooleanObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok || err.Message != expected.Message {
+				t.Errorf("expected error %q, got=%T (%+v)", expected.Message, evaluated, evaluated)
+			}
 		default:
 			t.Errorf("evaluted not %T. got=%T", evaluated, expected)
 		}
 	}
 }
 
+func TestStructTypes(t *testing.T) {
+	point := `struct Point {
+		x, y = 0
+		fn distsq(self, other) { let dx = self.x - other.x; let dy = self.y - other.y; dx * dx + dy * dy }
+		fn move(self, dx) { self.x = self.x + dx; self }
+	}; `
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{point + `Point(1, 2).y`, 2},
+		{point + `Point(1).y`, 0},
+		{point + `Point(0, 0).distsq(Point(3, 4))`, 25},
+		{point + `let p = Point(1); p.move(2); p.x`, 3},
+		{point + `type(Point(1))`, "Point"},
+		{point + `",".join(methods(Point(1)))`, "distsq,move"},
+		{point + `Point()`, newError(STRUCTERROR, "missing value for field 'x' of Point")},
+		{point + `Point(1, 2, 3)`, newError(ARGUMENTERROR, "at most 2", 3)},
+		{point + `Point(1).distsq()`, newError(ARGUMENTERROR, "1", 0)},
+		{point + `let p = Point(1); p.z = 1`, newError(STRUCTERROR, "Point has no field 'z'")},
+		{`let x = 1; x.y = 2`, newError(STRUCTERROR, "can't assign field 'y' of INTEGER")},
+		{`struct Box { items = [] }; let a = Box(); a.items.push(1); len(Box().items)`, 0},
+		{`struct Range { lo, hi = lo + 10 }; Range(5).hi`, 15},
+		{`let st = struct(a->1); st.a = 2; st.a`, 2},
+		{`struct Counter { n = 0; fn inc(self) { self.n = self.n + 1 } }; let c = Counter(); c.inc(); c.inc(); c.n`, 2},
+		// a struct named like a built-in type is still a struct
+		{`struct INTEGER { v }; let a = INTEGER(1); a + a`, newError(INFIXOP, "+", "INTEGER", "INTEGER")},
+		{`struct STRING { v }; let a = STRING("x"); a + a`, newError(INFIXOP, "+", "STRING", "STRING")},
+		{`struct ERROR { v }; let a = ERROR(1); a.v`, 1},
+		{`struct ERROR { v }; type(ERROR(1))`, "ERROR"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+
+	p := testEval(point + `Point(1, 2)`)
+	if p.Inspect() != "Point{x: 1, y: 2}" {
+		t.Errorf("wrong Inspect for struct instance. got=%s", p.Inspect())
+	}
+}
+
+func TestStructEmbedding(t *testing.T) {
+	shapes := `struct Shape {
+		name = "shape"
+		fn describe(self) { self.name + " with area " + str(self.area()) }
+		fn area(self) { 0 }
+	};
+	struct Rect(Shape) {
+		w, h
+		fn area(self) { self.w * self.h }
+	};
+	struct Square(Rect) {
+		fn describe(self) { "square: " + Rect.describe(self) }
+	}; `
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{shapes + `Rect("r", 2, 3).area()`, 6},
+		{shapes + `Rect("r", 2, 3).describe()`, "r with area 6"},
+		{shapes + `Square("sq", 2, 2).describe()`, "square: sq with area 4"},
+		{shapes + `Square("sq", 2, 2).w`, 2},
+		{shapes + `let s = Square("sq", 2, 2); s.w = 5; s.area()`, 10},
+		{shapes + `type(Square("sq", 1, 1))`, "Square"},
+		{shapes + `",".join(methods(Square("sq", 1, 1)))`, "area,describe"},
+		{shapes + `implements(Rect("r", 1, 1), ["area", "describe"])`, true},
+		{shapes + `implements(Rect("r", 1, 1), ["area", "perimeter"])`, false},
+		{shapes + `implements(Square("sq", 1, 1), Shape)`, true},
+		{shapes + `implements(1, Shape)`, false},
+		{shapes + `implements("a", ["upper"])`, true},
+		{shapes + `Rect.area(Shape())`, newError(STRUCTERROR, "Rect.area needs a Rect receiver, got Shape")},
+		{shapes + `Shape.area(Rect("r", 2, 2))`, 0},
+		{shapes + `Rect("r", 1)`, newError(STRUCTERROR, "missing value for field 'h' of Rect")},
+		{`let x = 1; struct P(x) { y }`, newError(STRUCTERROR, "P can't embed INTEGER")},
+		{`struct P { x }; struct Q(P) { x }`, newError(STRUCTERROR, "Q redeclares field 'x' of P")},
+		{`struct P { x = 1 }; struct Q(P) { y = x + 1 }; Q().y`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+
+	sq := testEval(shapes + `Square("sq", 2, 3)`)
+	if sq.Inspect() != `Square{name: sq, w: 2, h: 3}` {
+		t.Errorf("wrong Inspect for embedded struct. got=%s", sq.Inspect())
+	}
+}
+
+func TestStructProtocols(t *testing.T) {
+	vector := `struct Vec {
+		x, y
+		fn __add__(self, other) { Vec(self.x + other.x, self.y + other.y) }
+		fn __mul__(self, k) { Vec(self.x * k, self.y * k) }
+		fn __rmul__(self, k) { self * k }
+		fn __neg__(self) { Vec(-self.x, -self.y) }
+		fn __eq__(self, other) { if (type(other) == "Vec") { self.x == other.x and self.y == other.y } else { false } }
+		fn __lt__(self, other) { self.x < other.x }
+		fn __index__(self, i) { [self.x, self.y][i] }
+		fn __len__(self) { 2 }
+		fn __str__(self) { "<" + str(self.x) + ", " + str(self.y) + ">" }
+		fn __iter__(self) { [self.x, self.y] }
+	}; `
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{vector + `str(Vec(1, 2) + Vec(3, 4))`, "<4, 6>"},
+		{vector + `str(Vec(1, 2) * 3)`, "<3, 6>"},
+		{vector + `str(3 * Vec(1, 2))`, "<3, 6>"},
+		{vector + `str(-Vec(1, 2))`, "<-1, -2>"},
+		// negation leaves the operand unchanged
+		{vector + `let a = Vec(1, 2); let b = -a; str(a)`, "<1, 2>"},
+		{vector + `Vec(1, 2) == Vec(1, 2)`, true},
+		{vector + `Vec(1, 2) != Vec(1, 2)`, false},
+		{vector + `Vec(1, 2) != 1`, true},
+		{vector + `Vec(1, 2) < Vec(2, 0)`, true},
+		{vector + `Vec(5, 7)[1]`, 7},
+		{vector + `len(Vec(5, 7))`, 2},
+		{vector + `array(Vec(5, 7)).map(fn(x) { x * 2 })[1]`, 14},
+		{vector + `len(set(Vec(5, 5)))`, 1},
+		{vector + `Vec(1, 2) - Vec(1, 2)`, newError(INFIXOP, "-", "Vec", "Vec")},
+		{`struct Bad { fn __len__(self) { "two" } }; len(Bad())`, newError(RTERROR, "INTEGER")},
+		{`struct Bad { fn __iter__(self) { 1 } }; array(Bad())`, newError(RTERROR, "ARRAY")},
+		{`struct Plain { x }; Plain(1)[0]`, newError(NOINDEXERROR, "Plain")},
+		{`let st = struct(a->1); addm(st, "__len__", fn() { self.a + 4 }); len(st)`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+
+	v := testEval(vector + `Vec(1, 2)`)
+	if v.Inspect() != "<1, 2>" {
+		t.Errorf("Inspect does not use __str__. got=%s", v.Inspect())
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`assert(1 < 2)`, nil},
+		{`let x = 4; assert(x == 3)`, newError(ASSERTERROR, "(x == 3) is false: 4 == 3")},
+		{`let f = fn(n) { n * 2 }; assert(f(2) != 4, "doubling")`, newError(ASSERTERROR, "doubling: (f(2) != 4) is false: 4 != 4")},
+		{`assert(false and true)`, newError(ASSERTERROR, "(false and true) is false")},
+		{`assert(1 < "a")`, newError(INFIXOP, "<", "INTEGER", "STRING")},
+		{`assert()`, newError(ARGUMENTERROR, "1 or 2", 0)},
+		{`assert_eq([1, {"a" -> [2]}], [1, {"a" -> [2]}])`, nil},
+		{`assert_eq(set([1, 2]), set([2, 1]))`, nil},
+		{"struct Pt { x }\nassert_eq(Pt(1), Pt(1))", nil},
+		{"struct Pt { x }\nassert_eq(Pt(1), Pt(2))", newError(ASSERTERROR, "got Pt{x: 1}, want Pt{x: 2}")},
+		{`assert_eq([1, 2], [1, 3])`, newError(ASSERTERROR, "got [1, 2], want [1, 3]")},
+		{`assert_eq(1, "1", "types")`, newError(ASSERTERROR, "types: got 1 (INTEGER), want 1 (STRING)")},
+		{`assert_raises(fn() { 1 + "a" })`, "unsupported operator for infix expression: '+' and types INTEGER and STRING"},
+		{`assert_raises(fn() { nope }, "not defined")`, "unknown identifier: 'nope' is not defined"},
+		{`assert_raises(fn() { 1 })`, newError(ASSERTERROR, "no error raised, got 1")},
+		{`assert_raises(fn() { nope }, "index")`, newError(ASSERTERROR, "error 'unknown identifier: 'nope' is not defined' doesn't contain 'index'")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
 func TestIncludeObjects(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -111,18 +357,159 @@ func TestIncludeObjects(t *testing.T) {
 		p := parser.New(l, path)
 		s := NewScope(nil)
 		program := p.ParseProgram()
-		if len(program.Includes) == 0 {
-			t.Errorf("Parsed program has no statements or included objects.\n")
-			os.Exit(1)
-		}
 		results := Eval(program, s)
-		if len(includeScope.store) != 3 {
-			t.Fatalf("program doesn't inlcude 3 modules. got=%d", len(includeScope.store))
+		for _, name := range []string{"eval", "test", "pkg"} {
+			if _, ok := s.Get(name); !ok {
+				t.Fatalf("program didn't bind included module %s in its scope", name)
+			}
 		}
 		testIntegerObject(t, results, tt.expected)
 	}
 }
 
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "test_files/imports/geometry" as geo; geo.area(2, 3)`, 6},
+		{`import "test_files/imports/geometry"; geometry.sq(4)`, 16},
+		{`import "test_files/imports/geometry" as g; g.unit`, 1},
+		{`import "test_files/imports/geometry" as g; g.hidden`, newError(NOMETHODERROR, "g.hidden", INCLUDED_OBJ)},
+		{`import "test_files/imports/geometry" as g; g.square(2)`, newError(NOMETHODERROR, "g.square(2)", INCLUDED_OBJ)},
+		{`from "test_files/imports/geometry" import area, unit; area(unit, 7)`, 7},
+		{`from "test_files/imports/geometry" import square`, newError(IMPORTERROR, "square", "test_files/imports/geometry")},
+		{`from "test_files/imports/plain" import a, b; b(a)`, 2},
+		{`import "test_files/imports/plain" as p; let a = 10; p.b(a)`, 11},
+		{`import "test_files/imports/plain" as p; a`, newError(UNKNOWNIDENT, "a")},
+		{`import "test_files/sub_package" as sp; type(sp)`, INCLUDED_OBJ},
+		{`from math import max, pow, sum; max(pow(2, 10), sum([1, 2, 3]))`, 1024},
+		{`import functional as f; str(f.range(0, 4))`, "[0, 1, 2, 3]"},
+		{`import "test_files/imports/left" as l; import "test_files/imports/right" as r; let a = l.s; let b = r.s; a == b`, true},
+		{`import "test_files/imports/nothere"`, newError(MODULEERROR, `cannot find module "test_files/imports/nothere", tried:`)},
+		{`import "test_files/imports/cycle_a"`, newError(MODULEERROR, "import cycle detected: ")},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		path, _ := os.Getwd()
+		p := parser.New(l, path+"/../parser")
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		evaluated := Eval(program, NewScope(nil))
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if !strings.HasPrefix(err.Message, expected.Message) {
+				t.Errorf("wrong error message. expected prefix=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
+func TestIncludeInSourceOrder(t *testing.T) {
+	input := `let base = 21; include order; doubled`
+	p := parser.New(lexer.New(input), "../parser/test_files/imports")
+	testIntegerObject(t, Eval(p.ParseProgram(), NewScope(nil)), 42)
+}
+
+func TestOutputWriter(t *testing.T) {
+	tests := []struct {
+		input   string
+		include string
+		output  string
+	}{
+		{`puts("a"); puts(1 + 2)`, "", "a\n3\n"},
+		{`include noisy; greet()`, "", "hello\n"},
+		{`include noisy; greet()`, "show", "loading\nhello\n"},
+		{`include noisy; greet()`, "capture", "hello\n"},
+	}
+
+	for _, tt := range tests {
+		var out, captured bytes.Buffer
+		s := NewScope(nil)
+		s.SetWriter(&out)
+		switch tt.include {
+		case "show":
+			s.SetIncludeWriter(s.Writer())
+		case "capture":
+			s.SetIncludeWriter(&captured)
+		}
+		p := parser.New(lexer.New(tt.input), "../parser/test_files/imports")
+		Eval(p.ParseProgram(), s)
+		if out.String() != tt.output {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.input, tt.output, out.String())
+		}
+		if tt.include == "capture" && captured.String() != "loading\n" {
+			t.Errorf("include output not captured. got=%q", captured.String())
+		}
+	}
+}
+
+func TestReloadModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := dir + "/counter.my"
+	ioutil.WriteFile(filename, []byte("export let value = 1"), 0644)
+
+	s := NewScope(nil)
+	run := func(input string) Object {
+		p := parser.New(lexer.New(input), dir)
+		return Eval(p.ParseProgram(), s)
+	}
+	testIntegerObject(t, run(`import counter; counter.value`), 1)
+
+	ioutil.WriteFile(filename, []byte("export let value = 2"), 0644)
+	testIntegerObject(t, run(`import counter; counter.value`), 1)
+	testIntegerObject(t, run(`reload(counter); counter.value`), 2)
+
+	ioutil.WriteFile(filename, []byte("export let value = "), 0644)
+	if _, ok := run(`reload(counter)`).(*Error); !ok {
+		t.Errorf("reload of a broken module did not return an error")
+	}
+	testIntegerObject(t, run(`counter.value`), 2)
+}
+
+func TestModulesPerInterpreter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/shared.my", []byte(`puts("loading")
+export let count = 0
+export let bump = fn() { count = count + 1; puts(count) }`), 0644)
+
+	var outA, outB bytes.Buffer
+	a, _ := NewInterpreter(Options{Stdout: &outA, Dir: dir})
+	b, _ := NewInterpreter(Options{Stdout: &outB, Dir: dir})
+	for _, in := range []*Interpreter{a, a, b} {
+		if _, err := in.Eval(`import shared; shared.bump()`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if outA.String() != "loading\n1\n2\n" {
+		t.Errorf("wrong output for the first interpreter. got=%q", outA.String())
+	}
+	if outB.String() != "loading\n1\n" {
+		t.Errorf("wrong output for the second interpreter. got=%q", outB.String())
+	}
+}
+
 func TestStringMethods(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -182,6 +569,50 @@ func TestStringMethods(t *testing.T) {
 		{`"These are the days of summer".count(" ")`, 5},
 		{`" ".join(["a", "b", "c"])`, "a b c"},
 		{`"!".join(["a", "b", "c"])`, "a!b!c"},
+		{`"monkey".starts_with("mon")`, true},
+		{`"monkey".starts_with("key")`, false},
+		{`"monkey".ends_with("key")`, true},
+		{`"monkey".contains("nk")`, true},
+		{`"monkey".contains("x")`, false},
+		{`"hello big world".title()`, "Hello Big World"},
+		{`"hELLO world".capitalize()`, "Hello world"},
+		{`"7".pad_left(3, "0")`, "007"},
+		{`"ab".pad_right(4)`, "ab  "},
+		{`"ab".center(6, "*")`, "**ab**"},
+		{`"abc".center(2)`, "abc"},
+		{`"ab".repeat(3)`, "ababab"},
+		{`"ab" * 2`, "abab"},
+		{`3 * "-"`, "---"},
+		{`"ab".repeat(-1)`, newError(INPUTERROR, "-1", "repeat")},
+		{`"ab".pad_left(-1)`, "ab"},
+		{`"ab".pad_left(5, "éé")`, newError(INLENERR, "pad_left", "1", "2")},
+		{`"ab".pad_right(5, "")`, newError(INLENERR, "pad_right", "1", "0")},
+		// counts too large to build fail instead of panicking
+		{`"ab" * 9223372036854775807`, newError(SIZELIMITERROR, STRING_OBJ, int64(math.MaxInt64), maxLength)},
+		{`"ab".pad_left(9223372036854775807)`, newError(SIZELIMITERROR, STRING_OBJ, int64(math.MaxInt64), maxLength)},
+		{`"ab".center(9223372036854775807, "é")`, newError(SIZELIMITERROR, STRING_OBJ, int64(math.MaxInt64), maxLength)},
+		{`str("a` + "\n" + `b` + "\r\n" + `c` + "\n" + `".lines())`, "[a, b, c]"},
+		{`str("abc".chars())`, "[a, b, c]"},
+		{`"123".is_digit()`, true},
+		{`"12a".is_digit()`, false},
+		{`"".is_digit()`, false},
+		{`"abc".is_alpha()`, true},
+		{`" ` + "\t" + `".is_space()`, true},
+		{`"xxhixyx".trim("xy")`, "hi"},
+		{`"  hi  ".trim()`, "hi"},
+		{`"xxhix".trim_left("x")`, "hix"},
+		{`"xxhix".trim_right("x")`, "xxhi"},
+		{`"abcabc".rfind("bc")`, 4},
+		{`"abc".rfind("x")`, NULL},
+		{`str("key=value=x".partition("="))`, "[key, =, value=x]"},
+		{`str("key".partition("="))`, "[key, , ]"},
+		{`"{} + {} = {}".format(1, 2, 3)`, "1 + 2 = 3"},
+		{`"{1}{0}{1}".format("a", "b")`, "bab"},
+		{`"{name} is {age}".format({"name"->"bob", "age"->5})`, "bob is 5"},
+		{`"{0}: {name}".format("id", {"name"->"bob"})`, "id: bob"},
+		{`"{{}} {}".format(1)`, "{} 1"},
+		{`"{}".format()`, newError(FORMATERROR, "not enough arguments for '{}'")},
+		{`"{x}".format({})`, newError(FORMATERROR, "no value for named field 'x'")},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -190,6 +621,8 @@ func TestStringMethods(t *testing.T) {
 			testIntegerObject(t, evaluated, int64(expected))
 		case *Null:
 			testNullObject(t, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
 		case string:
 			testStringObject(t, evaluated, expected)
 		case *Error:
@@ -199,6 +632,159 @@ func TestStringMethods(t *testing.T) {
 		}
 	}
 }
+func TestSetObjects(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`str({3, 1, 2, 1})`, "{3, 1, 2}"},
+		{`str(set())`, "set()"},
+		{`len({1, 2, 2})`, 2},
+		{`{1, 2}.contains(2)`, true},
+		{`{1, 2}.contains(3)`, false},
+		{`let s = {1}; s.add(2); s.add(1); str(s)`, "{1, 2}"},
+		{`let s = {1, 2, 3}; s.remove(2); str(s)`, "{1, 3}"},
+		{`str({1, 2}.union({2, 3}))`, "{1, 2, 3}"},
+		{`str({1, 2} | {2, 3})`, "{1, 2, 3}"},
+		{`str({1, 2, 3}.intersection({2, 3, 4}))`, "{2, 3}"},
+		{`str({1, 2, 3} & {2, 3, 4})`, "{2, 3}"},
+		{`str({1, 2, 3}.difference({2}))`, "{1, 3}"},
+		{`str({1, 2, 3} - {2})`, "{1, 3}"},
+		{`str({1, 2, 3}.symmetric_difference({3, 4}))`, "{1, 2, 4}"},
+		{`{1, 2}.is_subset({1, 2, 3})`, true},
+		{`{1, 4}.is_subset({1, 2, 3})`, false},
+		{`{1, 2, 3}.is_superset({3})`, true},
+		{`{1, 2} == {2, 1}`, true},
+		{`{1, 2} != {1}`, true},
+		{`str(set([1, "a", 1]))`, "{1, a}"},
+		{`str(array({"b", "a"}))`, "[b, a]"},
+		{`type({1})`, SET_OBJ},
+		{`{[1]}`, newError(KEYERROR, ARRAY_OBJ)},
+		{`{1}.add({})`, newError(KEYERROR, HASH_OBJ)},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
+func TestRegexObjects(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`re("a+b").match("xaab")`, true},
+		{`re("^a+b$").match("xaab")`, false},
+		{`re("\d+").find("abc 123 456")`, "123"},
+		{`re("\d+").find("abc")`, NULL},
+		{`str(re("\d+").find_all("a1 b22 c333"))`, "[1, 22, 333]"},
+		{`re("(?P<key>\w+)=(?P<value>\w+)").captures("x: a=b")["key"]`, "a"},
+		{`re("(?P<key>\w+)=(?P<value>\w+)").captures("x: a=b")["value"]`, "b"},
+		{`re("(\w+)@(\w+)").captures("me@host")[2]`, "host"},
+		{`re("(\w+)@(\w+)").captures("me@host")[0]`, "me@host"},
+		{`re("(\w+)@(\w+)").captures("nothing")`, NULL},
+		{`re("(\w+)=(\w+)").replace("a=b c=d", "$2=$1")`, "b=a d=c"},
+		{`re("(?P<k>\w+)=\w+").replace("a=b", "${k}")`, "a"},
+		{`re("\d+").replace("a1b22", fn(m) { str(len(m)) })`, "a1b2"},
+		{`str(re("\s*,\s*").split("a , b,c"))`, "[a, b, c]"},
+		{`re("a.c").pattern()`, "a.c"},
+		{`"log: error 42".match(re("error \d+"))`, true},
+		{`"log: error 42".match("warn")`, false},
+		{`str("a1b2".find_all("\d"))`, "[1, 2]"},
+		{`"a1b22".replace(re("\d+"), "#")`, "a#b#"},
+		{`str("a1b22c".split(re("\d+")))`, "[a, b, c]"},
+		{`type(re("a"))`, REGEX_OBJ},
+		{`re("(")`, newError(REGEXERROR, "error parsing regexp: missing closing ): `(`")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Null:
+			testNullObject(t, evaluated)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	config := `let config = json.parse(open("test_files/config.json").read());`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{config + `config["name"]`, "monkey"},
+		{config + `config["limits"]["depth"]`, 10},
+		{config + `str(config["tags"])`, "[lang, toy]"},
+		{config + `type(config["owner"])`, NULL_OBJ},
+		{config + `config["debug"]`, false},
+		{config + `str(config.keys())`, "[name, version, debug, tags, owner, limits]"},
+		{config + `json.stringify(config)`, `{"name":"monkey","version":3,"debug":false,"tags":["lang","toy"],"owner":null,"limits":{"depth":10,"steps":1000}}`},
+		{config + `let s = json.stringify(config, 2); json.stringify(json.parse(s), 2) == s`, true},
+		{`json.stringify({"z"->1, "a"->[1, "two", false], "m"->{"n"->true}})`, `{"z":1,"a":[1,"two",false],"m":{"n":true}}`},
+		{`json.stringify({1->"a"})`, `{"1":"a"}`},
+		{`json.stringify(struct(b->2, a->"x"))`, `{"a":"x","b":2}`},
+		{`struct Point { y, x }; json.stringify(Point(1, 2))`, `{"y":1,"x":2}`},
+		{`json.stringify({1, 2})`, `[1,2]`},
+		{`json.stringify("a" + chr(34))`, `"a\""`},
+		{`json.stringify([1, {"a"->2}], 2)`, "[\n  1,\n  {\n    \"a\": 2\n  }\n]"},
+		{`json.stringify([1], "	")`, "[\n\t1\n]"},
+		{`json.stringify([1], -1)`, newError(INPUTERROR, "-1", "json.stringify")},
+		{`json.stringify([1], 1000000000)`, newError(INPUTERROR, "1000000000", "json.stringify")},
+		{`json.parse("[1, 2, [3]]")[2][0]`, 3},
+		{`json.parse("[1, 2")`, newError(JSONERROR, "unexpected end of JSON input at offset 5")},
+		{`json.parse("[1.5]")`, newError(JSONERROR, "cannot represent number 1.5 as INTEGER at offset 4")},
+		{`json.parse("1 2")`, newError(JSONERROR, "unexpected data after top-level value at offset 3")},
+		{`json.stringify(fn(x) { x })`, newError(JSONERROR, "cannot serialize type FUNCTION")},
+		{`let a = [1]; a.push(a); json.stringify(a)`, newError(JSONERROR, "cannot serialize a ARRAY that contains itself")},
+		{`json.load("")`, newError(NOMETHODERROR, "load", "json")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
 func TestStringIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -470,7 +1056,7 @@ func TestBuiltinFunction(t *testing.T) {
 		{`len([1, 3, 5])`, 3},
 		{`len([1,2,3])`, 3},
 		{`"string".plus()`, "undefined method 'plus' for object STRING"},
-		{`"string".plus`, "undefined method 'string.plus' for object STRING"},
+		{`"string".plus`, "undefined method '\"string\".plus' for object STRING"},
 		{`len("one", "two")`, "wrong number of arguments. expected=1, got=2"},
 		{`len(1)`, "undefined method 'len' for object INTEGER"},
 		{`int("1")`, 1},
@@ -823,6 +1409,7 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10", 10},
 		{"-5", -5},
 		{"-10", -10},
+		{"let x = 5; let y = -x; x", 5},
 		{"5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -855,11 +1442,13 @@ func testEval(input string) Object {
 	p := parser.New(l, path)
 	s := NewScope(nil)
 	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Printf("Parser errors in %q: %v\n", input, p.Errors())
+		os.Exit(1)
+	}
 	if len(program.Statements) == 0 {
-		if len(program.Includes) == 0 {
-			fmt.Printf("Parsed program has no statements or included objects.\n")
-			os.Exit(1)
-		}
+		fmt.Printf("Parsed program has no statements.\n")
+		os.Exit(1)
 	}
 	return Eval(program, s)
 }
@@ -923,3 +1512,471 @@ func testInterpolatedStringObject(t *testing.T, obj Object, expected string) boo
 	}
 	return true
 }
+
+func TestInterpreter(t *testing.T) {
+	var out, errOut bytes.Buffer
+	in, err := NewInterpreter(Options{Stdout: &out, Stderr: &errOut, Dir: "../parser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.Set("config", map[string]interface{}{"name": "monkey", "tags": []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("add", func(a, b int) int { return a + b }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("fail", func(msg string) (string, error) { return "", fmt.Errorf("failed: %s", msg) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("join", func(sep string, parts ...string) string { return strings.Join(parts, sep) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("boom", func() { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("small", func(n int8) int8 { return n }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("unsigned", func(n uint) uint { return n }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Register("huge", func() uint64 { return math.MaxUint64 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("big", uint64(math.MaxUint64)); err == nil {
+		t.Errorf("setting a uint64 too large for an INTEGER did not fail")
+	}
+	if err := in.Register("bad", 42); err == nil {
+		t.Errorf("registering a non-function did not fail")
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`add(2, 3)`, int64(5)},
+		{`config["name"]`, "monkey"},
+		{`config["tags"][1]`, "b"},
+		{`join("-", "x", "y", "z")`, "x-y-z"},
+		{`small(-128) + unsigned(7)`, int64(-121)},
+		{`let double = fn(x) { puts(x * 2); eputs("err"); x * 2 }; double(add(1, 1))`, int64(4)},
+		{`let h = {"a" -> [1, true]}; h`, map[string]interface{}{"a": []interface{}{int64(1), true}}},
+	}
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.input, err)
+			continue
+		}
+		if got := FromObject(result); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+	if out.String() != "4\n" || errOut.String() != "err\n" {
+		t.Errorf("wrong output. stdout=%q, stderr=%q", out.String(), errOut.String())
+	}
+
+	result, err := in.Call("double", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, result, 42)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`fail("x")`, "failed: x"},
+		{`add("1", 2)`, "conversion error: argument 1 to add: cannot use STRING as int"},
+		{`add(1)`, "wrong number of arguments. expected=2, got=1"},
+		// integers that don't fit the Go type fail instead of wrapping
+		{`small(300)`, "conversion error: argument 1 to small: 300 overflows int8"},
+		{`unsigned(-1)`, "conversion error: argument 1 to unsigned: -1 overflows uint"},
+		{`huge()`, "conversion error: 18446744073709551615 overflows INTEGER"},
+		{`let f = fn(a, b) { a }; f(1)`, "wrong number of arguments. expected=2, got=1"},
+		{`let x = ;`, "parse error: "},
+		// a panic is returned as an error rather than stopping the host
+		{`boom()`, "internal error: boom"},
+	}
+	for _, tt := range errors {
+		_, err := in.Eval(tt.input)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Errorf("calling an undefined function did not fail")
+	}
+	if _, err := in.Call("boom"); err == nil || err.Error() != "internal error: boom" {
+		t.Errorf("expected the panic as an error, got=%v", err)
+	}
+
+	other, _ := NewInterpreter(Options{})
+	if _, ok := other.Get("double"); ok {
+		t.Errorf("globals leaked between interpreters")
+	}
+}
+
+func TestRegisteredBuiltinsAndMethods(t *testing.T) {
+	RegisterBuiltin("twice", 1, "twice(n) doubles n.", func(scope *Scope, args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	})
+	RegisterMethod(STRING_OBJ, "shout", 0, "shout() returns the string in upper case with a trailing !.",
+		func(scope *Scope, receiver Object, args ...Object) Object {
+			return &String{Value: strings.ToUpper(receiver.(*String).Value) + "!"}
+		})
+	RegisterMethod(STRUCT_OBJ, "field_count", 0, "field_count() returns the number of fields of the struct.",
+		func(scope *Scope, receiver Object, args ...Object) Object {
+			return &Integer{Value: int64(len(receiver.(*Struct).fieldValues()))}
+		})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`twice(21)`, 42},
+		{`twice(1, 2)`, newError(ARGUMENTERROR, "1", 2)},
+		{`"hi".shout()`, "HI!"},
+		{`"hi".shout(1)`, newError(ARGUMENTERROR, "0", 1)},
+		{`help("twice")`, "twice(n) doubles n."},
+		{`help("puts")`, "puts(value) writes value followed by a newline to the output."},
+		{`help("hi", "shout")`, "shout() returns the string in upper case with a trailing !."},
+		{`help("nothing")`, newError(NODOCERROR, "nothing")},
+		{`help(1, "shout")`, newError(NODOCERROR, "INTEGER.shout")},
+		{`set(methods("")).contains("shout")`, true},
+		// methods registered on STRUCT apply to structs of declared types too
+		{`struct Pair { a, b }; Pair(1, 2).field_count()`, 2},
+		{`set(methods("")).contains("starts_with")`, true},
+		{`implements("", ["pad_left", "is_digit", "find_all"])`, true},
+		{`set(help().lines()).contains("twice: twice(n) doubles n.")`, true},
+		// the arity of every builtin is checked before it's called
+		{`implements(1)`, newError(ARGUMENTERROR, "2", 1)},
+		{`eputs()`, newError(ARGUMENTERROR, "1", 0)},
+		{`puts(1, 2)`, newError(ARGUMENTERROR, "1", 2)},
+		{`json.parse()`, newError(ARGUMENTERROR, "1", 0)},
+		{`puts(nothing)`, newError(UNKNOWNIDENT, "nothing")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/data.txt", []byte("data"), 0644)
+
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		limits   Limits
+		ctx      context.Context
+		expected string
+	}{
+		{`do {}`, Limits{MaxSteps: 1000}, nil, "step limit error: exceeded 1000 steps"},
+		{`let a = [1, 2, 3].map(fn(x) { do {} })`, Limits{MaxSteps: 1000}, nil, "step limit error: exceeded 1000 steps"},
+		{`do {}`, Limits{}, expired, "timeout error: context canceled"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, Limits{MaxDepth: 50}, nil, "recursion error: maximum call depth of 50 exceeded"},
+		{`let f = fn(n) { f(n + 1) }; f(0)`, Limits{}, nil, fmt.Sprintf("recursion error: maximum call depth of %d exceeded", DefaultMaxDepth)},
+		// tasks share the program's step count, so spawning can't escape it
+		{`let f = fn() { spawn(f).join() }; f()`, Limits{MaxSteps: 2000}, nil, "step limit error: exceeded 2000 steps"},
+		{`let c = chan(); do { spawn(fn() { c.recv() }) }`, Limits{MaxTasks: 5}, nil, "task limit error: more than 5 tasks running"},
+		{`spawn(fn() { 1 })`, Limits{NoSpawn: true}, nil, "permission error: spawn is disabled"},
+		{`let a = []; do { a.push(1) }`, Limits{MaxCollectionSize: 10}, nil, "size limit error: ARRAY of size 11 exceeds the maximum of 10"},
+		{`"ab".repeat(10)`, Limits{MaxCollectionSize: 10}, nil, "size limit error: STRING of size 20 exceeds the maximum of 10"},
+		// long strings are rejected before they're built
+		{`"a" * 1000000000`, Limits{MaxCollectionSize: 10}, nil, "size limit error: STRING of size 1000000000 exceeds the maximum of 10"},
+		{`"ab".pad_left(100)`, Limits{MaxCollectionSize: 10}, nil, "size limit error: STRING of size 100 exceeds the maximum of 10"},
+		{`let x = "a"; '{x}b'.repeat(100)`, Limits{MaxCollectionSize: 10}, nil, "size limit error: STRING of size 200 exceeds the maximum of 10"},
+		{`let s = "abcdef"; s + s`, Limits{MaxCollectionSize: 10}, nil, "size limit error: STRING of size 12 exceeds the maximum of 10"},
+		{`open("` + dir + `/data.txt")`, Limits{FileAccess: FileDenied}, nil, "permission error: file access is disabled"},
+		{`open("/etc/passwd")`, Limits{FileAccess: FileRestricted, FileRoot: dir}, nil, "permission error: '/etc/passwd' is outside " + dir},
+		{`open("` + dir + `/../data.txt")`, Limits{FileAccess: FileRestricted, FileRoot: dir}, nil, "permission error: '" + dir + "/../data.txt' is outside " + dir},
+	}
+
+	for _, tt := range tests {
+		in, _ := NewInterpreter(Options{Limits: tt.limits})
+		ctx := tt.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		_, err := in.EvalContext(ctx, tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, err)
+		}
+		in.Close()
+	}
+
+	in, _ := NewInterpreter(Options{Limits: Limits{MaxSteps: 1000, FileAccess: FileRestricted, FileRoot: dir}})
+	result, err := in.Eval(`let f = open("` + dir + `/data.txt"); f.read()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStringObject(t, result, "data")
+	// the step count starts over for every evaluation
+	for i := 0; i < 3; i++ {
+		if _, err := in.Eval(`let a = [1, 2, 3].map(fn(x) { x * 2 })`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// methods called from Go through the interpreter are limited too
+	in, _ = NewInterpreter(Options{Limits: Limits{MaxCollectionSize: 10}})
+	result, err = in.CallMethod(&String{Value: "ab"}, "upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStringObject(t, result, "AB")
+	_, err = in.CallMethod(&String{Value: "ab"}, "repeat", 100)
+	if err == nil || err.Error() != "size limit error: STRING of size 200 exceeds the maximum of 10" {
+		t.Errorf("repeat called from Go was not limited. got=%v", err)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let t = spawn(fn(a, b) { a * b }, 6, 7); t.join()`, 42},
+		{`let t = spawn(fn() { 1 }); t.join(); t.done()`, true},
+		{`let c = chan(2); c.send(1); c.send(2); c.recv() + c.recv()`, 3},
+		{`let c = chan(); spawn(fn() { c.send(5) }); c.recv()`, 5},
+		{`let c = chan(1); c.close(); c.recv()`, nil},
+		{`let c = chan(1); c.close(); c.send(1)`, newError(CONCURRENCYERROR, "send on closed channel")},
+		{`let c = chan(1); c.close(); c.close()`, newError(CONCURRENCYERROR, "close of closed channel")},
+		{`chan(3).cap()`, 3},
+		// tasks can read a variable holding an interpolated string at once
+		{`let x = 1; let s = 'v{x}'; let a = spawn(fn() { s + s }); let b = spawn(fn() { s }); a.join() + b.join()`, "v1v1v1"},
+		{`let m = mutex(); m.unlock()`, newError(CONCURRENCYERROR, "unlock of unlocked mutex")},
+		{`let m = mutex(); m.lock(); m.try_lock()`, false},
+		{`waitgroup().done()`, newError(CONCURRENCYERROR, "negative waitgroup counter")},
+		{`spawn(fn() { 1 + true }).join()`, newError(INFIXOP, "+", "INTEGER", "BOOLEAN")},
+		{`spawn(1)`, newError(INPUTERROR, "INTEGER", "spawn")},
+		{`let results = chan(10); let wg = waitgroup(); let m = mutex(); let total = 0;
+		  let work = fn(n) { m.lock(); total = total + n; m.unlock(); results.send(n * n); wg.done() };
+		  let i = 0; do { if (i == 10) { break } wg.add(1); spawn(work, i); i = i + 1 };
+		  wg.wait(); results.close();
+		  let squares = 0; do { if (results.len() == 0) { break } squares = squares + results.recv() };
+		  total * 1000 + squares`, 45285},
+		{`let c = chan(1); c.send(3); select { case c.recv() as v { v * 2 } }`, 6},
+		{`let c = chan(1); select { case c.send(4) { "sent" } }; c.recv()`, 4},
+		{`let c = chan(); select { case c.recv() as v { v } default { "empty" } }`, "empty"},
+		{`let a = chan(); let b = chan(); spawn(fn() { b.send("b") });
+		  select { case a.recv() as v { v } case b.recv() as v { v } }`, "b"},
+		{`select { case 1.recv() as v { v } }`, newError(INPUTERROR, "INTEGER", "select")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case *Error:
+			err, ok := evaluated.(*Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%s, got=%s", expected.Message, err.Message)
+			}
+		}
+	}
+}
+
+func TestConcurrencyTimeout(t *testing.T) {
+	in, _ := NewInterpreter(Options{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := in.EvalContext(ctx, `let c = chan(); c.recv()`)
+	if err == nil || err.Error() != "timeout error: context deadline exceeded" {
+		t.Errorf("blocked recv was not interrupted. got=%v", err)
+	}
+}
+
+func TestMethodNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/data.txt", []byte("data"), 0644)
+
+	inputs := []string{`"a"`, `[1]`, `{"a" -> 1}`, `{1}`, `re("a")`, `open("` + dir + `/data.txt")`,
+		`let c = chan(1); c.close(); c`, `mutex()`, `waitgroup()`, `let t = spawn(fn() { 1 }); t.join(); t`}
+	seen := make(map[ObjectType]bool)
+	for _, input := range inputs {
+		obj := testEval(input)
+		seen[obj.Type()] = true
+		for _, name := range MethodNames(obj) {
+			result := callMethod(nil, obj, name)
+			if err, ok := result.(*Error); ok && strings.HasPrefix(err.Message, "undefined method") {
+				t.Errorf("%s has no method %s, which MethodNames lists", obj.Type(), name)
+			}
+		}
+	}
+	for typ := range typeMethods {
+		if !seen[typ] {
+			t.Errorf("methods of %s are not tested", typ)
+		}
+	}
+}
+
+// TestTypeMethodsComplete checks that typeMethods lists every method the
+// CallMethod and call switches of the package dispatch on.
+func TestTypeMethodsComplete(t *testing.T) {
+	types := map[string]ObjectType{
+		"Array": ARRAY_OBJ, "Channel": CHANNEL_OBJ, "FileObject": FILE_OBJ, "Hash": HASH_OBJ,
+		"Mutex": MUTEX_OBJ, "Regex": REGEX_OBJ, "Set": SET_OBJ, "String": STRING_OBJ,
+		"Task": TASK_OBJ, "WaitGroup": WAITGROUP_OBJ,
+	}
+	fset := gotoken.NewFileSet()
+	pkgs, err := goparser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkgs["eval"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || (fn.Name.Name != "CallMethod" && fn.Name.Name != "call") {
+				continue
+			}
+			recv := fn.Recv.List[0].Type.(*goast.StarExpr).X.(*goast.Ident).Name
+			goast.Inspect(fn.Body, func(n goast.Node) bool {
+				sw, ok := n.(*goast.SwitchStmt)
+				if !ok {
+					return true
+				}
+				if tag, ok := sw.Tag.(*goast.Ident); !ok || tag.Name != "method" {
+					return true
+				}
+				typ, ok := types[recv]
+				if !ok {
+					t.Errorf("%s dispatches methods, but isn't checked against typeMethods", recv)
+					return false
+				}
+				listed := make(map[string]bool)
+				for _, name := range typeMethods[typ] {
+					listed[name] = true
+				}
+				for _, stmt := range sw.Body.List {
+					for _, expr := range stmt.(*goast.CaseClause).List {
+						if lit, ok := expr.(*goast.BasicLit); ok && !listed[strings.Trim(lit.Value, `"`)] {
+							t.Errorf("method %s of %s is missing from typeMethods", lit.Value, typ)
+						}
+					}
+				}
+				return false
+			})
+		}
+	}
+}
+
+func TestTaskLifetime(t *testing.T) {
+	in, _ := NewInterpreter(Options{})
+	defer in.Close()
+	// tasks outlive the Eval that spawned them
+	if _, err := in.Eval(`let c = chan(); let t = spawn(fn() { c.recv() * 2 })`); err != nil {
+		t.Fatal(err)
+	}
+	result, err := in.Eval(`c.send(21); t.join()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, result, 42)
+
+	// and stop once the interpreter is closed, or the context passed to
+	// EvalContext is done
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, stop := range []func(){cancel, in.Close} {
+		result, err := in.EvalContext(ctx, `spawn(fn() { do {} })`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, ok := result.(*Task)
+		if !ok {
+			t.Fatalf("object is not Task. got=%T (%+v)", result, result)
+		}
+		stop()
+		select {
+		case <-task.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("task kept running after it was stopped")
+		}
+		if err, ok := task.result.(*Error); !ok || err.Message != "timeout error: context canceled" {
+			t.Errorf("wrong task result. got=%v", task.result)
+		}
+		ctx = context.Background()
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input string
+		code  int
+	}{
+		{`exit()`, 0},
+		{`exit(3); puts("unreachable")`, 3},
+		{`let f = fn(n) { if (n == 0) { exit(7) } f(n - 1) }; f(5); 1`, 7},
+		{`let i = 0; do { i = i + 1; if (i == 3) { exit(i) } }`, 3},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		in, _ := NewInterpreter(Options{Stdout: &out})
+		_, err := in.Eval(tt.input)
+		exit, ok := err.(*ExitError)
+		if !ok {
+			t.Errorf("%s: error is not *ExitError. got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if exit.Code != tt.code || out.Len() != 0 {
+			t.Errorf("%s: expected exit status %d and no output, got %d and %q", tt.input, tt.code, exit.Code, out.String())
+		}
+		if result, err := in.Eval(`1 + 1`); err != nil || result.Inspect() != "2" {
+			t.Errorf("interpreter did not recover after exit. got=%v, %v", result, err)
+		}
+	}
+	in, _ := NewInterpreter(Options{})
+	if _, err := in.Eval(`exit("a")`); err == nil || err.Error() != newError(INPUTERROR, "STRING", "exit").(*Error).Message {
+		t.Errorf("exit with a string should be an input error. got=%v", err)
+	}
+	if _, err := in.Eval(`1 +`); err == nil {
+		t.Errorf("expected a parse error")
+	} else if perr, ok := err.(*ParseError); !ok || len(perr.Errors) == 0 {
+		t.Errorf("error is not *ParseError. got=%T", err)
+	}
+}
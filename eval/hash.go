@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"sort"
 	"strings"
 )
 
@@ -18,8 +19,11 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash pairs are stored by HashKey. order records insertion order so that
+// iteration, Inspect and serialization are deterministic.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	order []HashKey
 }
 
 type Hashable interface {
@@ -30,7 +34,7 @@ func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s-> %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 	out.WriteString("{")
@@ -60,6 +64,31 @@ func (h *Hash) CallMethod(method string, args ...Object) Object {
 	return newError(NOMETHODERROR, method, h.Type())
 }
 
+// OrderedPairs returns the pairs of the hash in insertion order. Pairs that
+// were written to the Pairs map directly, rather than through Push, follow
+// in order of their inspected keys.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := []HashPair{}
+	seen := make(map[HashKey]bool)
+	for _, k := range h.order {
+		if pair, ok := h.Pairs[k]; ok && !seen[k] {
+			pairs = append(pairs, pair)
+			seen[k] = true
+		}
+	}
+	if len(pairs) == len(h.Pairs) {
+		return pairs
+	}
+	rest := []HashPair{}
+	for k, pair := range h.Pairs {
+		if !seen[k] {
+			rest = append(rest, pair)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Key.Inspect() < rest[j].Key.Inspect() })
+	return append(pairs, rest...)
+}
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 	if b.Value {
@@ -93,8 +122,8 @@ func (h *Hash) Filter(args ...Object) Object {
 		return newError(ARGUMENTERROR, "2", len(block.Literal.Parameters))
 	}
 	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
-	s := NewScope(nil)
-	for _, argument := range h.Pairs {
+	s := newDetachedScope(block.Scope)
+	for _, argument := range h.OrderedPairs() {
 		s.Set(block.Literal.Parameters[0].(*ast.Identifier).Value, argument.Key)
 		s.Set(block.Literal.Parameters[1].(*ast.Identifier).Value, argument.Value)
 		r, ok := Eval(block.Literal.Body, s).(*Boolean)
@@ -111,7 +140,7 @@ func (h *Hash) Filter(args ...Object) Object {
 
 func (h *Hash) Keys(args ...Object) Object {
 	keys := &Array{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		keys.Members = append(keys.Members, pair.Key)
 	}
 	return keys
@@ -129,8 +158,8 @@ func (h *Hash) Map(args ...Object) Object {
 		return newError(ARGUMENTERROR, "2", len(block.Literal.Parameters))
 	}
 	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
-	s := NewScope(nil)
-	for _, argument := range h.Pairs {
+	s := newDetachedScope(block.Scope)
+	for _, argument := range h.OrderedPairs() {
 		s.Set(block.Literal.Parameters[0].(*ast.Identifier).Value, argument.Key)
 		s.Set(block.Literal.Parameters[1].(*ast.Identifier).Value, argument.Value)
 		r := Eval(block.Literal.Body, s)
@@ -141,7 +170,7 @@ func (h *Hash) Map(args ...Object) Object {
 		if !ok {
 			newError(RTERROR, HASH_OBJ)
 		}
-		for _, v := range rh.Pairs {
+		for _, v := range rh.OrderedPairs() {
 			hash.Push(v.Key, v.Value)
 		}
 	}
@@ -157,10 +186,10 @@ func (h *Hash) Merge(args ...Object) Object {
 		return newError(ARGUMENTERROR, args[0].Type(), "hash.merge")
 	}
 	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
-	for _, v := range h.Pairs {
+	for _, v := range h.OrderedPairs() {
 		hash.Push(v.Key, v.Value)
 	}
-	for _, v := range m.Pairs {
+	for _, v := range m.OrderedPairs() {
 		hash.Push(v.Key, v.Value)
 	}
 	return hash
@@ -174,8 +203,15 @@ func (h *Hash) Pop(args ...Object) Object {
 	if !ok {
 		return newError(KEYERROR, args[0].Type())
 	}
-	if hashPair, ok := h.Pairs[hashable.HashKey()]; ok {
-		delete(h.Pairs, hashable.HashKey())
+	key := hashable.HashKey()
+	if hashPair, ok := h.Pairs[key]; ok {
+		delete(h.Pairs, key)
+		for i, k := range h.order {
+			if k == key {
+				h.order = append(h.order[:i], h.order[i+1:]...)
+				break
+			}
+		}
 		return hashPair.Value
 	}
 	return NULL
@@ -186,7 +222,11 @@ func (h *Hash) Push(args ...Object) Object {
 		return newError(ARGUMENTERROR, "2", len(args))
 	}
 	if hashable, ok := args[0].(Hashable); ok {
-		h.Pairs[hashable.HashKey()] = HashPair{Key: args[0], Value: args[1]}
+		key := hashable.HashKey()
+		if _, ok := h.Pairs[key]; !ok {
+			h.order = append(h.order, key)
+		}
+		h.Pairs[key] = HashPair{Key: args[0], Value: args[1]}
 	} else {
 		return newError(KEYERROR, args[0].Type())
 	}
@@ -195,7 +235,7 @@ func (h *Hash) Push(args ...Object) Object {
 
 func (h *Hash) Values(args ...Object) Object {
 	values := &Array{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		values.Members = append(values.Members, pair.Value)
 	}
 	return values
@@ -0,0 +1,248 @@
+package eval
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures an Interpreter. Zero values fall back to os.Stdout,
+// os.Stderr and the current working directory.
+type Options struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	// IncludeOutput receives output printed while included files are
+	// evaluated. It is discarded when nil.
+	IncludeOutput io.Writer
+	// Dir is the directory source passed to Eval is resolved against.
+	Dir          string
+	IncludePaths []string
+	Limits       Limits
+}
+
+// Interpreter runs monkey programs for a Go host. Each Interpreter has its own
+// global scope, so globals and registered functions aren't shared between
+// interpreters. Tasks its programs spawn keep running after Eval returns,
+// until Close is called.
+type Interpreter struct {
+	scope        *Scope
+	dir          string
+	includePaths []string
+	// ctx is done once the interpreter is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewInterpreter(opts Options) (*Interpreter, error) {
+	dir := opts.Dir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dir = wd
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	scope := NewScope(nil)
+	if opts.Stdout != nil {
+		scope.SetWriter(opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		scope.SetErrWriter(opts.Stderr)
+	}
+	if opts.IncludeOutput != nil {
+		scope.SetIncludeWriter(opts.IncludeOutput)
+	}
+	scope.SetLimits(opts.Limits)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Interpreter{scope: scope, dir: dir, includePaths: opts.IncludePaths, ctx: ctx, cancel: cancel}, nil
+}
+
+// Close stops the tasks spawned by the interpreter's programs. Programs
+// evaluated after Close stop with a timeout error.
+func (in *Interpreter) Close() {
+	in.cancel()
+}
+
+// context returns the context a program evaluated with ctx runs in, which is
+// done once ctx is or the interpreter is closed. Tasks the program spawns
+// inherit it, so they outlive the call to Eval.
+func (in *Interpreter) context(ctx context.Context) context.Context {
+	if ctx.Done() == nil {
+		return in.ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		select {
+		case <-in.ctx.Done():
+		case <-ctx.Done():
+		}
+	}()
+	return ctx
+}
+
+// Scope returns the interpreter's global scope.
+func (in *Interpreter) Scope() *Scope { return in.scope }
+
+// Eval parses and evaluates src in the global scope. Parse errors and runtime
+// errors are returned as errors; a parse error is a *ParseError, a runtime
+// error an *Error and a call to exit() an *ExitError.
+func (in *Interpreter) Eval(src string) (Object, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval, but stops with a timeout error once ctx is done.
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (Object, error) {
+	return in.eval(ctx, src, in.dir)
+}
+
+// EvalFile evaluates the file at path, resolved against the interpreter's
+// directory. Includes and imports in the file are relative to the file.
+func (in *Interpreter) EvalFile(path string) (Object, error) {
+	return in.EvalFileContext(context.Background(), path)
+}
+
+// EvalFileContext is like EvalFile, but stops with a timeout error once ctx
+// is done.
+func (in *Interpreter) EvalFileContext(ctx context.Context, path string) (Object, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(in.dir, path)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return in.eval(ctx, string(src), filepath.Dir(path))
+}
+
+// ParseError is returned when source passed to an Interpreter doesn't parse.
+// Errors holds the messages of Diagnostics, with their positions.
+type ParseError struct {
+	Errors      []string
+	Diagnostics []*parser.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// recoverError turns a panic while a program runs into an *Error returned
+// through err, so that a bug a script runs into doesn't stop the host.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = newError(INTERNALERROR, r).(*Error)
+	}
+}
+
+func (in *Interpreter) eval(ctx context.Context, src, dir string) (result Object, err error) {
+	defer recoverError(&err)
+	p := parser.New(lexer.New(src), dir)
+	p.SetIncludePaths(in.includePaths)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors(), Diagnostics: p.Diagnostics()}
+	}
+	in.scope.resetRuntime()
+	in.scope.SetContext(in.context(ctx))
+	result = Eval(program, in.scope)
+	if result == nil {
+		return NULL, nil
+	}
+	if err, ok := result.(*Error); ok {
+		return nil, hostError(err)
+	}
+	return result, nil
+}
+
+// Get returns the global bound to name.
+func (in *Interpreter) Get(name string) (Object, bool) {
+	return in.scope.Get(name)
+}
+
+// Set binds name in the global scope to value, converted with ToObject.
+func (in *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	in.scope.Set(name, obj)
+	return nil
+}
+
+// Register makes the Go function fn callable from scripts as name. See
+// NewGoFunction for the supported signatures.
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := NewGoFunction(name, fn)
+	if err != nil {
+		return err
+	}
+	in.scope.Set(name, builtin)
+	return nil
+}
+
+// Call calls the script function bound to name with args converted with
+// ToObject.
+func (in *Interpreter) Call(name string, args ...interface{}) (result Object, err error) {
+	defer recoverError(&err)
+	fn, ok := in.scope.Get(name)
+	if !ok {
+		return nil, newError(UNKNOWNIDENT, name).(*Error)
+	}
+	objs, err := toObjects(args)
+	if err != nil {
+		return nil, err
+	}
+	in.scope.resetRuntime()
+	in.scope.SetContext(in.ctx)
+	switch f := fn.(type) {
+	case *Function:
+		result = applyFunction(f, objs...)
+	case *Builtin:
+		result = f.call(in.scope, objs...)
+	default:
+		return nil, newError(NOTCALLABLE, fn.Type()).(*Error)
+	}
+	if err, ok := result.(*Error); ok {
+		return nil, hostError(err)
+	}
+	return result, nil
+}
+
+// CallMethod calls the method name of obj with args converted with ToObject,
+// as a script would, so that the interpreter's limits apply. Calling
+// obj.CallMethod directly bypasses them.
+func (in *Interpreter) CallMethod(obj Object, name string, args ...interface{}) (result Object, err error) {
+	defer recoverError(&err)
+	objs, err := toObjects(args)
+	if err != nil {
+		return nil, err
+	}
+	in.scope.resetRuntime()
+	in.scope.SetContext(in.ctx)
+	result = callMethod(in.scope, obj, name, objs...)
+	if err, ok := result.(*Error); ok {
+		return nil, hostError(err)
+	}
+	return result, nil
+}
+
+func toObjects(args []interface{}) ([]Object, error) {
+	objs := make([]Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
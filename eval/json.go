@@ -0,0 +1,245 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxJSONIndent is the most spaces json.stringify indents by.
+const maxJSONIndent = 64
+
+var jsonModule = &BuiltinModule{
+	Name: "json",
+	Functions: map[string]*Builtin{
+		"parse": &Builtin{
+			Name:  "parse",
+			Arity: 1,
+			Doc:   "json.parse(s) decodes the JSON text s into hashes, arrays and other values.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) != 1 {
+					return newError(ARGUMENTERROR, "1", len(args))
+				}
+				s, ok := args[0].(*String)
+				if !ok {
+					return newError(INPUTERROR, args[0].Type(), "json.parse")
+				}
+				return parseJSON(s.Value)
+			},
+		},
+		"stringify": &Builtin{
+			Name:  "stringify",
+			Arity: Variadic,
+			Doc:   "json.stringify(value[, indent]) returns value encoded as JSON, indented by indent spaces or by the string indent when it's given.",
+			Fn: func(scope *Scope, args ...Object) Object {
+				if len(args) < 1 || len(args) > 2 {
+					return newError(ARGUMENTERROR, "1 or 2", len(args))
+				}
+				var indent string
+				if len(args) == 2 {
+					switch i := args[1].(type) {
+					case *Integer:
+						if i.Value < 0 || i.Value > maxJSONIndent {
+							return newError(INPUTERROR, i.Inspect(), "json.stringify")
+						}
+						indent = strings.Repeat(" ", int(i.Value))
+					case *String:
+						indent = i.Value
+					default:
+						return newError(INPUTERROR, args[1].Type(), "json.stringify")
+					}
+				}
+				return stringifyJSON(args[0], indent)
+			},
+		},
+	},
+}
+
+// parseJSON decodes JSON text token by token so that object keys keep their
+// source order in the resulting Hash.
+func parseJSON(text string) Object {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	obj, err := decodeJSONValue(dec)
+	if err != nil {
+		return jsonDecodeError(err, dec)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError(JSONERROR, fmt.Sprintf("unexpected data after top-level value at offset %d", dec.InputOffset()))
+	}
+	return obj
+}
+
+func jsonDecodeError(err error, dec *json.Decoder) Object {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return newError(JSONERROR, fmt.Sprintf("%s at offset %d", e.Error(), e.Offset))
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newError(JSONERROR, fmt.Sprintf("unexpected end of JSON input at offset %d", dec.InputOffset()))
+	}
+	return newError(JSONERROR, fmt.Sprintf("%s at offset %d", err.Error(), dec.InputOffset()))
+}
+
+func decodeJSONValue(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				hash.Push(&String{Value: key.(string)}, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return hash, nil
+		case '[':
+			array := &Array{Members: []Object{}}
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				array.Members = append(array.Members, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return array, nil
+		}
+	case string:
+		return &String{Value: t}, nil
+	case json.Number:
+		i, err := t.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("cannot represent number %s as %s", t, INTEGER_OBJ)
+		}
+		return &Integer{Value: i}, nil
+	case bool:
+		return nativeBoolToBooleanObject(t), nil
+	case nil:
+		return NULL, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+func stringifyJSON(obj Object, indent string) Object {
+	var out bytes.Buffer
+	if err := encodeJSONValue(&out, obj, make(map[Object]bool)); err != nil {
+		return err
+	}
+	if indent == "" {
+		return &String{Value: out.String()}
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", indent); err != nil {
+		return newError(JSONERROR, err.Error())
+	}
+	return &String{Value: indented.String()}
+}
+
+// encodeJSONValue writes obj as compact JSON. Hash keys that aren't strings
+// are written as their inspected value, since JSON only allows string keys.
+// seen guards against collections that contain themselves.
+func encodeJSONValue(out *bytes.Buffer, obj Object, seen map[Object]bool) Object {
+	switch o := obj.(type) {
+	case *Null:
+		out.WriteString("null")
+	case *Boolean, *Integer:
+		out.WriteString(o.Inspect())
+	case *String, *InterpolatedString:
+		b, _ := json.Marshal(o.Inspect())
+		out.Write(b)
+	case *Array:
+		return encodeJSONArray(out, o, o.Members, seen)
+	case *Set:
+		return encodeJSONArray(out, o, o.Elements(), seen)
+	case *Hash:
+		if seen[o] {
+			return newError(JSONERROR, "cannot serialize a HASH that contains itself")
+		}
+		seen[o] = true
+		defer delete(seen, o)
+		out.WriteByte('{')
+		for i, pair := range o.OrderedPairs() {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			key, _ := json.Marshal(pair.Key.Inspect())
+			out.Write(key)
+			out.WriteByte(':')
+			if err := encodeJSONValue(out, pair.Value, seen); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case *Struct:
+		if seen[o] {
+			return newError(JSONERROR, "cannot serialize a STRUCT that contains itself")
+		}
+		seen[o] = true
+		defer delete(seen, o)
+		bindings := o.fieldValues()
+		// declared structs keep their field order, as Inspect does
+		var names []string
+		if o.typ != nil {
+			names = o.typ.fieldNames()
+		} else {
+			for name := range bindings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		out.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			key, _ := json.Marshal(name)
+			out.Write(key)
+			out.WriteByte(':')
+			if err := encodeJSONValue(out, bindings[name], seen); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	default:
+		return newError(JSONERROR, fmt.Sprintf("cannot serialize type %s", obj.Type()))
+	}
+	return nil
+}
+
+func encodeJSONArray(out *bytes.Buffer, container Object, members []Object, seen map[Object]bool) Object {
+	if seen[container] {
+		return newError(JSONERROR, fmt.Sprintf("cannot serialize a %s that contains itself", container.Type()))
+	}
+	seen[container] = true
+	defer delete(seen, container)
+	out.WriteByte('[')
+	for i, m := range members {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := encodeJSONValue(out, m, seen); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
@@ -0,0 +1,266 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxDepth bounds recursion when Limits.MaxDepth isn't set, so that a
+// runaway recursive function fails with an error instead of overflowing the
+// Go stack.
+const DefaultMaxDepth = 10000
+
+// DefaultMaxTasks bounds the tasks running at once when Limits.MaxTasks isn't
+// set.
+const DefaultMaxTasks = 10000
+
+// FileAccess is the policy for builtins that touch the file system.
+type FileAccess int
+
+const (
+	FileAllowed FileAccess = iota
+	FileDenied
+	// FileRestricted only allows files inside Limits.FileRoot.
+	FileRestricted
+)
+
+// Limits bounds the resources a program may use. Zero values mean no limit,
+// except MaxDepth and MaxTasks, which fall back to DefaultMaxDepth and
+// DefaultMaxTasks.
+type Limits struct {
+	// MaxSteps is the number of nodes the evaluator may visit.
+	MaxSteps int64
+	// MaxDepth is the deepest function call nesting allowed.
+	MaxDepth int
+	// MaxTasks is the number of spawned tasks that may run at once.
+	MaxTasks int
+	// NoSpawn makes spawn() fail. Arrays, hashes and sets aren't safe to
+	// modify from several tasks at once, and a script that does so can crash
+	// the host, so untrusted scripts should be run with it set.
+	NoSpawn bool
+	// MaxCollectionSize caps the length of strings, arrays, hashes and sets.
+	MaxCollectionSize int
+	FileAccess        FileAccess
+	FileRoot          string
+}
+
+// runtime holds the limits and counters shared by every scope of a running
+// program. Once a limit is exceeded err is set and evaluation unwinds. Each
+// task started with spawn() gets its own runtime, but callbacks may still run
+// on another task's runtime, so the counters are updated atomically.
+type runtime struct {
+	limits Limits
+	ctx    context.Context
+	// top is the runtime of the program that spawned the task, or nil for
+	// the program itself. Steps and tasks are counted there.
+	top   *runtime
+	steps int64
+	ticks int64
+	depth int64
+	tasks int64
+	err   atomic.Value
+	// loading is the stack of module files being evaluated, used to report
+	// import cycles
+	loading []string
+	// modules holds the program's evaluated file modules by absolute path,
+	// so a module that is included or imported from several places is only
+	// evaluated once.
+	modules   map[string]*IncludedObject
+	modulesMu sync.Mutex
+}
+
+// runtimeError boxes the limit error, since an atomic.Value can't hold nil.
+type runtimeError struct{ err Object }
+
+func (rt *runtime) failed() Object {
+	if e, ok := rt.err.Load().(runtimeError); ok {
+		return e.err
+	}
+	return nil
+}
+
+func (rt *runtime) fail(err Object) Object {
+	rt.err.Store(runtimeError{err})
+	return err
+}
+
+// fork returns a runtime for a new task, with the same limits and context
+// and its own call depth. Its steps count against the program's.
+func (rt *runtime) fork() *runtime {
+	return &runtime{limits: rt.limits, ctx: rt.ctx, top: rt.program()}
+}
+
+// program returns the runtime of the program rt belongs to.
+func (rt *runtime) program() *runtime {
+	if rt.top != nil {
+		return rt.top
+	}
+	return rt
+}
+
+// done returns a channel that is closed when the runtime's context is done,
+// or nil, which blocks forever, when there is no context.
+func (rt *runtime) done() <-chan struct{} {
+	if rt.ctx == nil {
+		return nil
+	}
+	return rt.ctx.Done()
+}
+
+// interrupted returns the error for a blocking operation cut short by the
+// runtime's context.
+func (rt *runtime) interrupted() Object {
+	return rt.fail(newError(TIMEOUTERROR, rt.ctx.Err().Error()))
+}
+
+// step counts one evaluation step and checks the step limit and context.
+func (rt *runtime) step() Object {
+	if err := rt.failed(); err != nil {
+		return err
+	}
+	steps := atomic.AddInt64(&rt.program().steps, 1)
+	if rt.limits.MaxSteps > 0 && steps > rt.limits.MaxSteps {
+		return rt.fail(newError(STEPLIMITERROR, rt.limits.MaxSteps))
+	}
+	// the context is checked on the runtime's own count, since a task may
+	// not see every multiple of the shared one
+	if rt.ctx != nil && atomic.AddInt64(&rt.ticks, 1)%64 == 0 {
+		if err := rt.ctx.Err(); err != nil {
+			return rt.fail(newError(TIMEOUTERROR, err.Error()))
+		}
+	}
+	return nil
+}
+
+func (rt *runtime) enter() Object {
+	max := rt.limits.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if atomic.AddInt64(&rt.depth, 1) > int64(max) {
+		atomic.AddInt64(&rt.depth, -1)
+		return rt.fail(newError(RECURSIONERROR, max))
+	}
+	return nil
+}
+
+func (rt *runtime) leave() {
+	atomic.AddInt64(&rt.depth, -1)
+}
+
+// startTask counts a task about to be spawned, failing if the program
+// already has MaxTasks running. endTask is called once the task returns.
+func (rt *runtime) startTask() Object {
+	max := rt.limits.MaxTasks
+	if max <= 0 {
+		max = DefaultMaxTasks
+	}
+	top := rt.program()
+	if atomic.AddInt64(&top.tasks, 1) > int64(max) {
+		atomic.AddInt64(&top.tasks, -1)
+		return rt.fail(newError(TASKLIMITERROR, max))
+	}
+	return nil
+}
+
+func (rt *runtime) endTask() {
+	atomic.AddInt64(&rt.program().tasks, -1)
+}
+
+// maxLength bounds the strings built by repeating and padding, with or
+// without limits, so that a huge count fails with an error instead of
+// exhausting memory.
+const maxLength = 1 << 30
+
+// checkLength fails if a collection of type t and length n, which is about to
+// be built, would be too long. It's called before building results that may
+// be large, since checkSize only sees them once they're allocated.
+func (rt *runtime) checkLength(t ObjectType, n int64) Object {
+	if n > maxLength {
+		return newError(SIZELIMITERROR, t, n, maxLength)
+	}
+	if max := rt.limits.MaxCollectionSize; max > 0 && n > int64(max) {
+		return rt.fail(newError(SIZELIMITERROR, t, n, max))
+	}
+	return nil
+}
+
+// checkSize fails if obj is a collection larger than MaxCollectionSize.
+func (rt *runtime) checkSize(obj Object) Object {
+	max := rt.limits.MaxCollectionSize
+	if max <= 0 || obj == nil {
+		return nil
+	}
+	var size int
+	switch o := obj.(type) {
+	case *String:
+		size = len(o.Value)
+	case *Array:
+		size = len(o.Members)
+	case *Hash:
+		size = len(o.Pairs)
+	case *Set:
+		size = len(o.Members)
+	default:
+		return nil
+	}
+	if size > max {
+		return rt.fail(newError(SIZELIMITERROR, obj.Type(), size, max))
+	}
+	return nil
+}
+
+// checkFile applies the file access policy to path.
+func (rt *runtime) checkFile(path string) Object {
+	switch rt.limits.FileAccess {
+	case FileDenied:
+		return newError(PERMISSIONERROR, "file access is disabled")
+	case FileRestricted:
+		root, err := filepath.Abs(rt.limits.FileRoot)
+		if err != nil {
+			return newError(PERMISSIONERROR, err.Error())
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return newError(PERMISSIONERROR, err.Error())
+		}
+		// resolve symlinks so a link inside the root can't point outside it
+		if r, err := filepath.EvalSymlinks(root); err == nil {
+			root = r
+		}
+		if a, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = a
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return newError(PERMISSIONERROR, "'"+path+"' is outside "+rt.limits.FileRoot)
+		}
+	}
+	return nil
+}
+
+// SetLimits sets the limits for programs evaluated in the scope and resets
+// the step count. It should be called on the global scope.
+func (s *Scope) SetLimits(l Limits) {
+	s.rt.limits = l
+	atomic.StoreInt64(&s.rt.steps, 0)
+	atomic.StoreInt64(&s.rt.ticks, 0)
+	atomic.StoreInt64(&s.rt.depth, 0)
+	s.rt.err.Store(runtimeError{})
+}
+
+// SetContext makes evaluation in the scope stop with an error once ctx is
+// done.
+func (s *Scope) SetContext(ctx context.Context) {
+	s.rt.ctx = ctx
+}
+
+// resetRuntime clears the step count and any limit error so the scope can be
+// used to evaluate another program.
+func (s *Scope) resetRuntime() {
+	s.SetLimits(s.rt.limits)
+}
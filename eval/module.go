@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"path/filepath"
+	"strings"
+)
+
+// cachedModule returns the module evaluated from filename, if the program rt
+// belongs to has loaded it. Each program, and so each Interpreter, has its
+// own modules.
+func (rt *runtime) cachedModule(filename string) (*IncludedObject, bool) {
+	top := rt.program()
+	top.modulesMu.Lock()
+	defer top.modulesMu.Unlock()
+	module, ok := top.modules[filename]
+	return module, ok
+}
+
+func (rt *runtime) cacheModule(filename string, module *IncludedObject) {
+	top := rt.program()
+	top.modulesMu.Lock()
+	defer top.modulesMu.Unlock()
+	if top.modules == nil {
+		top.modules = make(map[string]*IncludedObject)
+	}
+	top.modules[filename] = module
+}
+
+// parseModule reads and parses a resolved module file.
+func parseModule(rt *runtime, filename string, src []byte, includePaths []string) (*ast.Program, Object) {
+	for i, f := range rt.loading {
+		if f == filename {
+			cycle := append(append([]string{}, rt.loading[i:]...), filename)
+			return nil, newError(MODULEERROR, "import cycle detected: "+strings.Join(cycle, " -> "))
+		}
+	}
+	p := parser.New(lexer.New(string(src)), filepath.Dir(filename))
+	p.SetIncludePaths(includePaths)
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		for i, msg := range errors {
+			errors[i] = filename + ":" + msg
+		}
+		return nil, newError(MODULEERROR, strings.Join(errors, "; "))
+	}
+	return program, nil
+}
+
+// evalModule evaluates a module's program into scope.
+func evalModule(filename string, program *ast.Program, scope *Scope) Object {
+	rt := scope.rt
+	rt.loading = append(rt.loading, filename)
+	defer func() { rt.loading = rt.loading[:len(rt.loading)-1] }()
+	return evalProgram(program, scope)
+}
+
+// loadModule resolves importpath relative to dir and returns the module
+// object for it, evaluating the module the first time it is loaded. When the
+// path names a directory module and splice is set, its module.my is instead
+// evaluated directly into the scope s and nil is returned. Output produced
+// while the module is evaluated goes to out.
+func loadModule(name, importpath, dir string, includePaths []string, s *Scope, out io.Writer, splice bool) (*IncludedObject, Object) {
+	filename, src, isDir, err := parser.ResolveModule(dir, includePaths, importpath)
+	if err != nil {
+		return nil, newError(MODULEERROR, err.Error())
+	}
+	if isDir && splice {
+		program, err := parseModule(s.rt, filename, src, includePaths)
+		if err != nil {
+			return nil, err
+		}
+		w := s.writer
+		s.SetWriter(out)
+		result := evalModule(filename, program, s)
+		s.SetWriter(w)
+		if result.Type() == ERROR_OBJ {
+			return nil, result
+		}
+		return nil, nil
+	}
+	if module, ok := s.rt.cachedModule(filename); ok {
+		return module, nil
+	}
+	program, perr := parseModule(s.rt, filename, src, includePaths)
+	if perr != nil {
+		return nil, perr
+	}
+	module := &IncludedObject{Name: name, Path: filename, Scope: NewScope(nil), includePaths: includePaths}
+	module.Scope.rt = s.rt
+	module.Scope.SetWriter(out)
+	if result := evalModule(filename, program, module.Scope); result.Type() == ERROR_OBJ {
+		return nil, result
+	}
+	// functions defined by the module write wherever the importer does
+	module.Scope.SetWriter(s.Writer())
+	s.rt.cacheModule(filename, module)
+	return module, nil
+}
+
+// reloadModule re-reads a module from disk and evaluates it again. The module
+// object is updated in place, so every binding of it sees the new code. If
+// the new version fails to parse or evaluate, the old scope is kept.
+func reloadModule(module *IncludedObject) Object {
+	src, err := parser.ReadModuleFile(module.Path)
+	if err != nil {
+		return newError(MODULEERROR, err.Error())
+	}
+	program, perr := parseModule(module.Scope.rt, module.Path, src, module.includePaths)
+	if perr != nil {
+		return perr
+	}
+	scope := NewScope(nil)
+	scope.rt = module.Scope.rt
+	scope.SetWriter(module.Scope.Writer())
+	if result := evalModule(module.Path, program, scope); result.Type() == ERROR_OBJ {
+		return result
+	}
+	module.Scope = scope
+	return module
+}
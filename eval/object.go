@@ -10,20 +10,28 @@ type ObjectType string
 
 // INTEGER_OBJ/*_OBJ = object types
 const (
-	INTEGER_OBJ      = "INTEGER"
-	BOOLEAN_OBJ      = "BOOLEAN"
-	NULL_OBJ         = "NULL"
-	RETURN_VALUE_OBJ = "RETURN_VALUE"
-	BREAK_OBJ        = "BREAK"
-	ERROR_OBJ        = "ERROR"
-	FUNCTION_OBJ     = "FUNCTION"
-	STRING_OBJ       = "STRING"
-	BUILTIN_OBJ      = "BUILTIN"
-	ARRAY_OBJ        = "ARRAY"
-	HASH_OBJ         = "HASH"
-	INCLUDED_OBJ     = "INCLUDE"
-	STRUCT_OBJ       = "STRUCT"
-	FILE_OBJ         = "FILE"
+	INTEGER_OBJ        = "INTEGER"
+	BOOLEAN_OBJ        = "BOOLEAN"
+	NULL_OBJ           = "NULL"
+	RETURN_VALUE_OBJ   = "RETURN_VALUE"
+	BREAK_OBJ          = "BREAK"
+	ERROR_OBJ          = "ERROR"
+	FUNCTION_OBJ       = "FUNCTION"
+	STRING_OBJ         = "STRING"
+	BUILTIN_OBJ        = "BUILTIN"
+	ARRAY_OBJ          = "ARRAY"
+	HASH_OBJ           = "HASH"
+	INCLUDED_OBJ       = "INCLUDE"
+	STRUCT_OBJ         = "STRUCT"
+	STRUCT_TYPE_OBJ    = "STRUCT_TYPE"
+	FILE_OBJ           = "FILE"
+	SET_OBJ            = "SET"
+	REGEX_OBJ          = "REGEX"
+	BUILTIN_MODULE_OBJ = "BUILTIN_MODULE"
+	TASK_OBJ           = "TASK"
+	CHANNEL_OBJ        = "CHANNEL"
+	MUTEX_OBJ          = "MUTEX"
+	WAITGROUP_OBJ      = "WAITGROUP"
 )
 
 type Object interface {
@@ -35,12 +43,22 @@ type Object interface {
 type Struct struct {
 	Scope   *Scope
 	methods map[string]*Function
+	// typ is the declared type of the struct, nil for struct literals
+	typ *StructType
 }
 
 func (s *Struct) Inspect() string {
+	if s.hasMethod("__str__") {
+		if str, ok := s.CallMethod("__str__").(*String); ok {
+			return str.Value
+		}
+	}
+	if s.typ != nil {
+		return s.inspectTyped()
+	}
 	var out bytes.Buffer
 	out.WriteString("( ")
-	for k, v := range s.Scope.store {
+	for k, v := range s.Scope.bindings() {
 		out.WriteString(k)
 		out.WriteString("->")
 		out.WriteString(v.Inspect())
@@ -52,17 +70,33 @@ func (s *Struct) Inspect() string {
 }
 
 func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+
+// typeName returns the name type() reports for obj: the declared name of a
+// struct's type, or obj's ObjectType. Struct types can't be their ObjectType,
+// since a struct named e.g. INTEGER would then pass for an integer.
+func typeName(obj Object) string {
+	if s, ok := obj.(*Struct); ok && s.typ != nil {
+		return s.typ.Name
+	}
+	return string(obj.Type())
+}
+
 func (s *Struct) CallMethod(method string, args ...Object) Object {
 	fn, ok := s.methods[method]
 	if !ok {
-		return newError(NOMETHODERROR, method, s.Type())
+		if s.typ != nil {
+			if fn, ok := s.typ.lookupMethod(method); ok {
+				return s.callTypeMethod(fn, args...)
+			}
+		}
+		return newError(NOMETHODERROR, method, typeName(s))
 	}
-	fn.Scope = NewScope(nil)
-	fn.Scope.Set("self", s)
+	scope := newDetachedScope(fn.Scope)
+	scope.Set("self", s)
 	for i, v := range fn.Literal.Parameters {
-		fn.Scope.Set(v.String(), args[i])
+		scope.Set(v.String(), args[i])
 	}
-	r := Eval(fn.Literal.Body, fn.Scope)
+	r := Eval(fn.Literal.Body, scope)
 	if obj, ok := r.(*ReturnValue); ok {
 		return obj.Value
 	}
@@ -77,7 +111,15 @@ func (b *Builtin) CallMethod(method string, args ...Object) Object {
 
 type IncludedObject struct {
 	Name  string
+	Path  string
 	Scope *Scope
+
+	includePaths []string
+}
+
+// Get returns a binding exported by the included module.
+func (io *IncludedObject) Get(name string) (Object, bool) {
+	return io.Scope.Exported(name)
 }
 
 func (io *IncludedObject) Inspect() string  { return fmt.Sprintf("included object: %s", io.Name) }
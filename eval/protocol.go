@@ -0,0 +1,107 @@
+package eval
+
+// Structs take part in operators and builtins by defining protocol methods,
+// e.g. `fn __add__(self, other) { ... }`.
+
+// operatorMethods maps infix operators to the protocol methods implementing
+// them.
+var operatorMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"%":  "__mod__",
+	"<":  "__lt__",
+	">":  "__gt__",
+	"==": "__eq__",
+	"!=": "__ne__",
+}
+
+// reflectedMethods are tried on the right operand when the left one doesn't
+// implement the operator, so that `2 * v` works as well as `v * 2`.
+var reflectedMethods = map[string]string{
+	"+": "__radd__",
+	"-": "__rsub__",
+	"*": "__rmul__",
+	"/": "__rdiv__",
+	"%": "__rmod__",
+}
+
+// hasMethod reports whether the struct or its type defines method.
+func (s *Struct) hasMethod(method string) bool {
+	if _, ok := s.methods[method]; ok {
+		return true
+	}
+	if s.typ != nil {
+		_, ok := s.typ.lookupMethod(method)
+		return ok
+	}
+	return false
+}
+
+// protocolMethod returns obj as a struct if it defines method.
+func protocolMethod(obj Object, method string) (*Struct, bool) {
+	s, ok := obj.(*Struct)
+	if !ok || !s.hasMethod(method) {
+		return nil, false
+	}
+	return s, true
+}
+
+// evalOperatorMethod applies operator through a protocol method of either
+// operand. Without __ne__, != negates __eq__.
+func evalOperatorMethod(operator string, left, right Object) (Object, bool) {
+	if method, ok := operatorMethods[operator]; ok {
+		if s, ok := protocolMethod(left, method); ok {
+			return s.CallMethod(method, right), true
+		}
+	}
+	if method, ok := reflectedMethods[operator]; ok {
+		if s, ok := protocolMethod(right, method); ok {
+			return s.CallMethod(method, left), true
+		}
+	}
+	if operator == "!=" {
+		if s, ok := protocolMethod(left, "__eq__"); ok {
+			eq := s.CallMethod("__eq__", right)
+			if eq.Type() == ERROR_OBJ {
+				return eq, true
+			}
+			return nativeBoolToBooleanObject(!objectToNativeBoolean(eq)), true
+		}
+	}
+	return nil, false
+}
+
+// callProtocol calls a protocol method that must return a value of type want.
+func callProtocol(s *Struct, method string, want ObjectType, args ...Object) Object {
+	result := s.CallMethod(method, args...)
+	if result.Type() != ERROR_OBJ && result.Type() != want {
+		return newError(RTERROR, want)
+	}
+	return result
+}
+
+// iterate returns the members of a collection passed to the builtin caller:
+// an array, a set or a struct whose __iter__ method returns an array or set.
+func iterate(obj Object, caller string) ([]Object, Object) {
+	switch o := obj.(type) {
+	case *Array:
+		return o.Members, nil
+	case *Set:
+		return o.Elements(), nil
+	case *Struct:
+		if o.hasMethod("__iter__") {
+			switch members := o.CallMethod("__iter__").(type) {
+			case *Array:
+				return members.Members, nil
+			case *Set:
+				return members.Elements(), nil
+			case *Error:
+				return nil, members
+			}
+			return nil, newError(RTERROR, ARRAY_OBJ)
+		}
+	}
+	return nil, newError(INPUTERROR, obj.Type(), caller)
+}
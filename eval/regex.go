@@ -0,0 +1,151 @@
+package eval
+
+import (
+	"regexp"
+)
+
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+func (r *Regex) Inspect() string  { return "re(\"" + r.Regexp.String() + "\")" }
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) CallMethod(method string, args ...Object) Object {
+	switch method {
+	case "captures":
+		return r.Captures(args...)
+	case "find":
+		return r.Find(args...)
+	case "find_all":
+		return r.FindAll(args...)
+	case "match":
+		return r.Match(args...)
+	case "pattern":
+		return r.Pattern(args...)
+	case "replace":
+		return r.Replace(args...)
+	case "split":
+		return r.Split(args...)
+	}
+	return newError(NOMETHODERROR, method, r.Type())
+}
+
+func newRegex(pattern string) Object {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return newError(REGEXERROR, err.Error())
+	}
+	return &Regex{Regexp: re}
+}
+
+func (r *Regex) Pattern(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	return &String{Value: r.Regexp.String()}
+}
+
+func (r *Regex) Match(args ...Object) Object {
+	str, err := stringArgument("match", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(r.Regexp.MatchString(str))
+}
+
+func (r *Regex) Find(args ...Object) Object {
+	str, err := stringArgument("find", args)
+	if err != nil {
+		return err
+	}
+	loc := r.Regexp.FindStringIndex(str)
+	if loc == nil {
+		return NULL
+	}
+	return &String{Value: str[loc[0]:loc[1]]}
+}
+
+func (r *Regex) FindAll(args ...Object) Object {
+	str, err := stringArgument("find_all", args)
+	if err != nil {
+		return err
+	}
+	a := &Array{Members: []Object{}}
+	for _, m := range r.Regexp.FindAllString(str, -1) {
+		a.Members = append(a.Members, &String{Value: m})
+	}
+	return a
+}
+
+// Captures returns a hash of the groups in the first match. Named groups are
+// keyed by name, unnamed groups by their index, and 0 holds the whole match.
+func (r *Regex) Captures(args ...Object) Object {
+	str, err := stringArgument("captures", args)
+	if err != nil {
+		return err
+	}
+	m := r.Regexp.FindStringSubmatchIndex(str)
+	if m == nil {
+		return NULL
+	}
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	for i, name := range r.Regexp.SubexpNames() {
+		var value Object = NULL
+		if m[2*i] >= 0 {
+			value = &String{Value: str[m[2*i]:m[2*i+1]]}
+		}
+		if name != "" {
+			hash.Push(&String{Value: name}, value)
+		} else {
+			hash.Push(&Integer{Value: int64(i)}, value)
+		}
+	}
+	return hash
+}
+
+// Replace substitutes every match. The replacement is either a string, which
+// may reference groups as $1 or ${name}, or a function called with the
+// matched text whose return value is inserted.
+func (r *Regex) Replace(args ...Object) Object {
+	if len(args) != 2 {
+		return newError(ARGUMENTERROR, "2", len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return newError(INPUTERROR, args[0].Type(), "replace")
+	}
+	switch repl := args[1].(type) {
+	case *String:
+		return &String{Value: r.Regexp.ReplaceAllString(str.Value, repl.Value)}
+	case *Function:
+		var err Object
+		out := r.Regexp.ReplaceAllStringFunc(str.Value, func(m string) string {
+			if err != nil {
+				return m
+			}
+			result := applyFunction(repl, &String{Value: m})
+			if result.Type() == ERROR_OBJ {
+				err = result
+				return m
+			}
+			return result.Inspect()
+		})
+		if err != nil {
+			return err
+		}
+		return &String{Value: out}
+	}
+	return newError(INPUTERROR, args[1].Type(), "replace")
+}
+
+func (r *Regex) Split(args ...Object) Object {
+	str, err := stringArgument("split", args)
+	if err != nil {
+		return err
+	}
+	a := &Array{Members: []Object{}}
+	for _, s := range r.Regexp.Split(str, -1) {
+		a.Members = append(a.Members, &String{Value: s})
+	}
+	return a
+}
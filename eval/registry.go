@@ -0,0 +1,199 @@
+package eval
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variadic is the Arity of builtins and methods that check their own
+// argument count.
+const Variadic = -1
+
+// MethodFunc implements a method registered on a type. receiver is the object
+// the method was called on.
+type MethodFunc func(scope *Scope, receiver Object, args ...Object) Object
+
+// Method is a method added to a type with RegisterMethod.
+type Method struct {
+	Fn    MethodFunc
+	Name  string
+	Arity int
+	Doc   string
+}
+
+var methodRegistry = make(map[ObjectType]map[string]*Method)
+
+// RegisterBuiltin adds a global builtin function, replacing any builtin with
+// the same name. Unless arity is Variadic, calls with a different number of
+// arguments fail before fn is called. Builtins should be registered before
+// any program is evaluated.
+func RegisterBuiltin(name string, arity int, doc string, fn BuiltinFunc) {
+	builtins[name] = &Builtin{Fn: fn, Name: name, Arity: arity, Doc: doc}
+}
+
+// RegisterMethod adds the method name to every object of type t. Registered
+// methods take precedence over the type's own methods.
+func RegisterMethod(t ObjectType, name string, arity int, doc string, fn MethodFunc) {
+	if methodRegistry[t] == nil {
+		methodRegistry[t] = make(map[string]*Method)
+	}
+	methodRegistry[t][name] = &Method{Fn: fn, Name: name, Arity: arity, Doc: doc}
+}
+
+// LookupMethod returns the method registered as name on type t.
+func LookupMethod(t ObjectType, name string) (*Method, bool) {
+	m, ok := methodRegistry[t][name]
+	return m, ok
+}
+
+func checkArity(arity int, args []Object) Object {
+	if arity != Variadic && len(args) != arity {
+		return newError(ARGUMENTERROR, strconv.Itoa(arity), len(args))
+	}
+	return nil
+}
+
+// callMethod dispatches a method call, trying registered methods before the
+// object's CallMethod.
+func callMethod(scope *Scope, obj Object, name string, args ...Object) Object {
+	if m, ok := LookupMethod(obj.Type(), name); ok {
+		if err := checkArity(m.Arity, args); err != nil {
+			return err
+		}
+		return m.Fn(scope, obj, args...)
+	}
+	if sc, ok := obj.(scopedCaller); ok {
+		return sc.call(scope, name, args...)
+	}
+	return obj.CallMethod(name, args...)
+}
+
+// typeMethods lists the methods each type's CallMethod supports, by the names
+// scripts call them with. TestTypeMethodsComplete fails when a CallMethod
+// dispatches on a name that isn't listed.
+var typeMethods = map[ObjectType][]string{
+	ARRAY_OBJ:   {"count", "filter", "index", "map", "merge", "pop", "push", "reduce"},
+	CHANNEL_OBJ: {"cap", "close", "len", "recv", "send"},
+	FILE_OBJ:    {"close", "read", "readline"},
+	HASH_OBJ:    {"filter", "keys", "map", "merge", "pop", "push", "values"},
+	MUTEX_OBJ:   {"lock", "try_lock", "unlock"},
+	REGEX_OBJ:   {"captures", "find", "find_all", "match", "pattern", "replace", "split"},
+	SET_OBJ: {"add", "contains", "difference", "intersection", "is_subset", "is_superset",
+		"len", "remove", "symmetric_difference", "union"},
+	STRING_OBJ: {"capitalize", "center", "chars", "contains", "count", "ends_with", "find",
+		"find_all", "format", "is_alpha", "is_digit", "is_space", "join", "lines", "lower",
+		"lstrip", "match", "pad_left", "pad_right", "partition", "repeat", "replace",
+		"reverse", "rfind", "rstrip", "split", "starts_with", "strip", "title", "trim",
+		"trim_left", "trim_right", "upper"},
+	TASK_OBJ:      {"done", "join"},
+	WAITGROUP_OBJ: {"add", "done", "wait"},
+}
+
+// MethodNames returns the names of the methods obj supports: those of its
+// type, registered methods and, for structs, the struct's own methods.
+func MethodNames(obj Object) []string {
+	names := append([]string{}, typeMethods[obj.Type()]...)
+	names = append(names, registeredMethodNames(obj.Type())...)
+	if st, ok := obj.(*Struct); ok {
+		names = append(names, st.methodNames()...)
+	}
+	return names
+}
+
+// BuiltinNames returns the names of every builtin in sorted order.
+func BuiltinNames() []string {
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinArity returns the number of arguments the builtin name takes, which
+// is Variadic for builtins that check their own.
+func BuiltinArity(name string) (int, bool) {
+	b, ok := builtins[name]
+	if !ok {
+		return 0, false
+	}
+	return b.Arity, true
+}
+
+// LookupBuiltin returns the builtin function name.
+func LookupBuiltin(name string) (*Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// LookupBuiltinModule returns the builtin module name, such as json.
+func LookupBuiltinModule(name string) (*BuiltinModule, bool) {
+	m, ok := builtinModules[name]
+	return m, ok
+}
+
+// BuiltinModuleNames returns the names of the builtin modules, such as json,
+// in sorted order.
+func BuiltinModuleNames() []string {
+	names := []string{}
+	for name := range builtinModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func registeredMethodNames(t ObjectType) []string {
+	names := []string{}
+	for name := range methodRegistry[t] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// help describes a builtin by name, a method on a value, or with no
+// arguments lists every builtin.
+func help(args ...Object) Object {
+	switch len(args) {
+	case 0:
+		lines := []string{}
+		for _, name := range BuiltinNames() {
+			lines = append(lines, name+": "+summary(builtins[name].Doc))
+		}
+		return &String{Value: strings.Join(lines, "\n")}
+	case 1:
+		name, ok := args[0].(*String)
+		if !ok {
+			return newError(INPUTERROR, args[0].Type(), "help")
+		}
+		b, ok := builtins[name.Value]
+		if !ok || b.Doc == "" {
+			return newError(NODOCERROR, name.Value)
+		}
+		return &String{Value: b.Doc}
+	case 2:
+		name, ok := args[1].(*String)
+		if !ok {
+			return newError(INPUTERROR, args[1].Type(), "help")
+		}
+		m, ok := LookupMethod(args[0].Type(), name.Value)
+		if !ok || m.Doc == "" {
+			return newError(NODOCERROR, string(args[0].Type())+"."+name.Value)
+		}
+		return &String{Value: m.Doc}
+	}
+	return newError(ARGUMENTERROR, "0 to 2", len(args))
+}
+
+// summary returns the first sentence of a docstring.
+func summary(doc string) string {
+	if doc == "" {
+		return "undocumented"
+	}
+	if i := strings.Index(doc, ". "); i >= 0 {
+		return doc[:i+1]
+	}
+	return doc
+}
@@ -1,17 +1,55 @@
 package eval
 
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
 func NewScope(p *Scope) *Scope {
 	s := make(map[string]Object)
-	return &Scope{store: s, parentScope: p}
+	rt := &runtime{}
+	if p != nil {
+		rt = p.rt
+	}
+	return &Scope{store: s, parentScope: p, rt: rt}
+}
+
+// newDetachedScope returns a scope without a parent that still shares the
+// limits and output writers of from, for evaluating callbacks and methods
+// that shouldn't see the caller's bindings.
+func newDetachedScope(from *Scope) *Scope {
+	s := NewScope(nil)
+	if from != nil {
+		s.rt = from.rt
+		s.writer = from.Writer()
+		s.errWriter = from.ErrWriter()
+	}
+	return s
 }
 
+// Scope bindings are guarded by mu, since tasks started with spawn() share
+// the scopes their functions were defined in.
 type Scope struct {
+	mu          sync.RWMutex
 	store       map[string]Object
 	parentScope *Scope
+	// exports is set on module scopes that contain export statements
+	exports map[string]bool
+	// writer and includeWriter are inherited from the parent scope when nil
+	writer        io.Writer
+	errWriter     io.Writer
+	includeWriter io.Writer
+	// rt is shared by every scope of a running program
+	rt *runtime
 }
 
 func (s *Scope) Get(name string) (Object, bool) {
+	s.mu.RLock()
 	obj, ok := s.store[name]
+	s.mu.RUnlock()
 	if !ok && s.parentScope != nil {
 		obj, ok = s.parentScope.Get(name)
 	}
@@ -19,17 +57,119 @@ func (s *Scope) Get(name string) (Object, bool) {
 }
 
 func (s *Scope) Set(name string, val Object) Object {
+	s.mu.Lock()
 	s.store[name] = val
+	s.mu.Unlock()
 	return val
 }
 
 func (s *Scope) Reset(name string, val Object) (Object, bool) {
+	s.mu.Lock()
 	_, ok := s.store[name]
 	if ok {
 		s.store[name] = val
 	}
+	s.mu.Unlock()
 	if !ok && s.parentScope != nil {
 		_, ok = s.parentScope.Reset(name, val)
 	}
 	return val, ok
 }
+
+// Export marks name as visible to importers of the module owning the scope.
+func (s *Scope) Export(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exports == nil {
+		s.exports = make(map[string]bool)
+	}
+	s.exports[name] = true
+}
+
+// Exported looks up a top level binding as seen from outside the module. When
+// the module has no export statements all of its bindings are visible.
+func (s *Scope) Exported(name string) (Object, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.exports != nil && !s.exports[name] {
+		return nil, false
+	}
+	obj, ok := s.store[name]
+	return obj, ok
+}
+
+// bindings returns a copy of the scope's own bindings.
+func (s *Scope) bindings() map[string]Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := make(map[string]Object, len(s.store))
+	for name, obj := range s.store {
+		b[name] = obj
+	}
+	return b
+}
+
+// Names returns the names bound in the scope and its parents.
+func (s *Scope) Names() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for ; s != nil; s = s.parentScope {
+		for name := range s.bindings() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetWriter sets where program output, such as puts, is written for this
+// scope and every scope created from it.
+func (s *Scope) SetWriter(w io.Writer) {
+	s.writer = w
+}
+
+// Writer returns the output sink for the scope, defaulting to os.Stdout.
+func (s *Scope) Writer() io.Writer {
+	for ; s != nil; s = s.parentScope {
+		if s.writer != nil {
+			return s.writer
+		}
+	}
+	return os.Stdout
+}
+
+// SetErrWriter sets where error output, such as eputs, is written.
+func (s *Scope) SetErrWriter(w io.Writer) {
+	s.errWriter = w
+}
+
+// ErrWriter returns the error output sink for the scope, defaulting to
+// os.Stderr.
+func (s *Scope) ErrWriter() io.Writer {
+	for ; s != nil; s = s.parentScope {
+		if s.errWriter != nil {
+			return s.errWriter
+		}
+	}
+	return os.Stderr
+}
+
+// SetIncludeWriter sets where output produced while evaluating an included
+// file goes. Pass the scope's Writer() to show it or a buffer to capture it.
+func (s *Scope) SetIncludeWriter(w io.Writer) {
+	s.includeWriter = w
+}
+
+// IncludeWriter returns the sink for include-time output. By default that
+// output is discarded.
+func (s *Scope) IncludeWriter() io.Writer {
+	for ; s != nil; s = s.parentScope {
+		if s.includeWriter != nil {
+			return s.includeWriter
+		}
+	}
+	return ioutil.Discard
+}
@@ -0,0 +1,257 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Set members are keyed by their HashKey, while order keeps insertion order
+// so that iteration and Inspect output are deterministic.
+type Set struct {
+	Members map[HashKey]Object
+	order   []HashKey
+}
+
+func NewSet(members ...Object) (*Set, Object) {
+	s := &Set{Members: make(map[HashKey]Object)}
+	for _, m := range members {
+		if err := s.add(m); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Set) Inspect() string {
+	if len(s.order) == 0 {
+		return "set()"
+	}
+	var out bytes.Buffer
+	members := []string{}
+	for _, m := range s.Elements() {
+		members = append(members, m.Inspect())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(members, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+
+func (s *Set) CallMethod(method string, args ...Object) Object {
+	switch method {
+	case "add":
+		return s.Add(args...)
+	case "contains":
+		return s.Contains(args...)
+	case "difference":
+		return s.Difference(args...)
+	case "intersection":
+		return s.Intersection(args...)
+	case "is_subset":
+		return s.IsSubset(args...)
+	case "is_superset":
+		return s.IsSuperset(args...)
+	case "len":
+		return s.Len(args...)
+	case "remove":
+		return s.Remove(args...)
+	case "symmetric_difference":
+		return s.SymmetricDifference(args...)
+	case "union":
+		return s.Union(args...)
+	}
+	return newError(NOMETHODERROR, method, s.Type())
+}
+
+// Elements returns the members of the set in insertion order.
+func (s *Set) Elements() []Object {
+	members := []Object{}
+	for _, k := range s.order {
+		members = append(members, s.Members[k])
+	}
+	return members
+}
+
+func (s *Set) add(o Object) Object {
+	hashable, ok := o.(Hashable)
+	if !ok {
+		return newError(KEYERROR, o.Type())
+	}
+	key := hashable.HashKey()
+	if _, ok := s.Members[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.Members[key] = o
+	return nil
+}
+
+func (s *Set) has(o Object) bool {
+	hashable, ok := o.(Hashable)
+	if !ok {
+		return false
+	}
+	_, ok = s.Members[hashable.HashKey()]
+	return ok
+}
+
+func (s *Set) Add(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENTERROR, "1", len(args))
+	}
+	if err := s.add(args[0]); err != nil {
+		return err
+	}
+	return s
+}
+
+func (s *Set) Contains(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENTERROR, "1", len(args))
+	}
+	if _, ok := args[0].(Hashable); !ok {
+		return newError(KEYERROR, args[0].Type())
+	}
+	return nativeBoolToBooleanObject(s.has(args[0]))
+}
+
+func (s *Set) Len(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	return &Integer{Value: int64(len(s.order))}
+}
+
+func (s *Set) Remove(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENTERROR, "1", len(args))
+	}
+	hashable, ok := args[0].(Hashable)
+	if !ok {
+		return newError(KEYERROR, args[0].Type())
+	}
+	key := hashable.HashKey()
+	if _, ok := s.Members[key]; !ok {
+		return s
+	}
+	delete(s.Members, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return s
+}
+
+func setArgument(name string, args []Object) (*Set, Object) {
+	if len(args) != 1 {
+		return nil, newError(ARGUMENTERROR, "1", len(args))
+	}
+	other, ok := args[0].(*Set)
+	if !ok {
+		return nil, newError(INPUTERROR, args[0].Type(), name)
+	}
+	return other, nil
+}
+
+func (s *Set) Union(args ...Object) Object {
+	other, err := setArgument("union", args)
+	if err != nil {
+		return err
+	}
+	set, _ := NewSet(s.Elements()...)
+	for _, m := range other.Elements() {
+		set.add(m)
+	}
+	return set
+}
+
+func (s *Set) Intersection(args ...Object) Object {
+	other, err := setArgument("intersection", args)
+	if err != nil {
+		return err
+	}
+	set, _ := NewSet()
+	for _, m := range s.Elements() {
+		if other.has(m) {
+			set.add(m)
+		}
+	}
+	return set
+}
+
+func (s *Set) Difference(args ...Object) Object {
+	other, err := setArgument("difference", args)
+	if err != nil {
+		return err
+	}
+	set, _ := NewSet()
+	for _, m := range s.Elements() {
+		if !other.has(m) {
+			set.add(m)
+		}
+	}
+	return set
+}
+
+func (s *Set) SymmetricDifference(args ...Object) Object {
+	other, err := setArgument("symmetric_difference", args)
+	if err != nil {
+		return err
+	}
+	set, _ := NewSet()
+	for _, m := range s.Elements() {
+		if !other.has(m) {
+			set.add(m)
+		}
+	}
+	for _, m := range other.Elements() {
+		if !s.has(m) {
+			set.add(m)
+		}
+	}
+	return set
+}
+
+func (s *Set) IsSubset(args ...Object) Object {
+	other, err := setArgument("is_subset", args)
+	if err != nil {
+		return err
+	}
+	for _, m := range s.Elements() {
+		if !other.has(m) {
+			return FALSE
+		}
+	}
+	return TRUE
+}
+
+func (s *Set) IsSuperset(args ...Object) Object {
+	other, err := setArgument("is_superset", args)
+	if err != nil {
+		return err
+	}
+	return other.IsSubset(s)
+}
+
+func evalSetInfixExpression(operator string, left Object, right Object) Object {
+	l := left.(*Set)
+	r := right.(*Set)
+
+	switch operator {
+	case "|":
+		return l.Union(r)
+	case "&":
+		return l.Intersection(r)
+	case "-":
+		return l.Difference(r)
+	case "==":
+		return nativeBoolToBooleanObject(len(l.order) == len(r.order) && l.IsSubset(r) == TRUE)
+	case "!=":
+		return nativeBoolToBooleanObject(len(l.order) != len(r.order) || l.IsSubset(r) == FALSE)
+	}
+	return newError(INFIXOP, operator, l.Type(), r.Type())
+}
@@ -2,7 +2,11 @@ package eval
 
 import (
 	"bytes"
+	"math"
 	"monkey/ast"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 type InterpolatedString struct {
@@ -12,7 +16,7 @@ type InterpolatedString struct {
 }
 
 type Interpolable interface {
-	Interpolate(scope *Scope)
+	Interpolate(scope *Scope) *String
 }
 
 func (is *InterpolatedString) Inspect() string  { return is.Value.Value }
@@ -21,14 +25,19 @@ func (is *InterpolatedString) CallMethod(method string, args ...Object) Object {
 	return is.Value.CallMethod(method, args...)
 }
 
-func (is *InterpolatedString) Interpolate(scope *Scope) {
+func (is *InterpolatedString) call(scope *Scope, method string, args ...Object) Object {
+	return is.Value.call(scope, method, args...)
+}
+
+// Interpolate returns the string with the values its expressions have in
+// scope. is itself is left unchanged, so that tasks can share it.
+func (is *InterpolatedString) Interpolate(scope *Scope) *String {
 	var out bytes.Buffer
 
 	objIndex := "0"[0]
 	ol := len(is.Expressions)
 	if ol == 0 {
-		is.Value.Value = is.RawValue
-		return
+		return &String{Value: is.RawValue}
 	}
 	mStr := "{" + string(objIndex) + "}"
 	sl := len(is.RawValue)
@@ -54,7 +63,7 @@ func (is *InterpolatedString) Interpolate(scope *Scope) {
 			out.WriteByte(is.RawValue[i])
 		}
 	}
-	is.Value.Value = out.String()
+	return &String{Value: out.String()}
 }
 
 func (is *InterpolatedString) evalInterpExpression(exp ast.Expression, s *Scope) string {
@@ -81,6 +90,20 @@ type String struct{ Value string }
 
 func (s *String) Inspect() string  { return s.Value }
 func (s *String) Type() ObjectType { return STRING_OBJ }
+
+// call is CallMethod for callers with a scope: the methods that may build
+// long strings check them against the scope's limits first. CallMethod only
+// applies maxLength; Go callers use Interpreter.CallMethod for the limits.
+func (s *String) call(scope *Scope, method string, args ...Object) Object {
+	switch method {
+	case "pad_left", "pad_right", "center":
+		return s.pad(scope.runtime(), method, args)
+	case "repeat":
+		return s.repeat(scope.runtime(), args)
+	}
+	return s.CallMethod(method, args...)
+}
+
 func (s *String) CallMethod(method string, args ...Object) Object {
 
 	switch method {
@@ -106,6 +129,50 @@ func (s *String) CallMethod(method string, args ...Object) Object {
 		return s.Count(args...)
 	case "join":
 		return s.Join(args...)
+	case "starts_with":
+		return s.StartsWith(args...)
+	case "ends_with":
+		return s.EndsWith(args...)
+	case "contains":
+		return s.Contains(args...)
+	case "title":
+		return s.Title(args...)
+	case "capitalize":
+		return s.Capitalize(args...)
+	case "pad_left":
+		return s.PadLeft(args...)
+	case "pad_right":
+		return s.PadRight(args...)
+	case "center":
+		return s.Center(args...)
+	case "repeat":
+		return s.Repeat(args...)
+	case "lines":
+		return s.Lines(args...)
+	case "chars":
+		return s.Chars(args...)
+	case "is_digit":
+		return s.IsDigit(args...)
+	case "is_alpha":
+		return s.IsAlpha(args...)
+	case "is_space":
+		return s.IsSpace(args...)
+	case "trim":
+		return s.Trim(args...)
+	case "trim_left":
+		return s.TrimLeft(args...)
+	case "trim_right":
+		return s.TrimRight(args...)
+	case "rfind":
+		return s.Rfind(args...)
+	case "partition":
+		return s.Partition(args...)
+	case "format":
+		return s.Format(args...)
+	case "match":
+		return s.Match(args...)
+	case "find_all":
+		return s.FindAll(args...)
 	}
 	return newError(NOMETHODERROR, method, s.Type())
 }
@@ -278,6 +345,9 @@ func (s *String) Replace(args ...Object) Object {
 	if len(args) != 2 {
 		return newError(ARGUMENTERROR, "1", len(args))
 	}
+	if re, ok := args[0].(*Regex); ok {
+		return re.Replace(s, args[1])
+	}
 
 	mObj, ok := args[0].(*String)
 	if !ok {
@@ -364,6 +434,9 @@ func (s *String) Split(args ...Object) Object {
 	var del string
 
 	if len(args) == 1 {
+		if re, ok := args[0].(*Regex); ok {
+			return re.Split(s)
+		}
 		sObj, ok := args[0].(*String)
 		if !ok {
 			return newError(INPUTERROR, args[0].Type(), "rstrip")
@@ -446,3 +519,406 @@ func (s *String) Upper(args ...Object) Object {
 	}
 	return &String{Value: out.String()}
 }
+
+func stringArgument(name string, args []Object) (string, Object) {
+	if len(args) != 1 {
+		return "", newError(ARGUMENTERROR, "1", len(args))
+	}
+	sObj, ok := args[0].(*String)
+	if !ok {
+		return "", newError(INPUTERROR, args[0].Type(), name)
+	}
+	return sObj.Value, nil
+}
+
+func (s *String) StartsWith(args ...Object) Object {
+	prefix, err := stringArgument("starts_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasPrefix(s.Value, prefix))
+}
+
+func (s *String) EndsWith(args ...Object) Object {
+	suffix, err := stringArgument("ends_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasSuffix(s.Value, suffix))
+}
+
+func (s *String) Contains(args ...Object) Object {
+	sub, err := stringArgument("contains", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.Contains(s.Value, sub))
+}
+
+// Title upper cases the first letter of every word and lower cases the rest.
+func (s *String) Title(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	var out bytes.Buffer
+	start := true
+	for _, ch := range s.Value {
+		if unicode.IsLetter(ch) {
+			if start {
+				out.WriteRune(unicode.ToUpper(ch))
+			} else {
+				out.WriteRune(unicode.ToLower(ch))
+			}
+			start = false
+			continue
+		}
+		start = !unicode.IsDigit(ch)
+		out.WriteRune(ch)
+	}
+	return &String{Value: out.String()}
+}
+
+func (s *String) Capitalize(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	if s.Value == "" {
+		return s
+	}
+	r := []rune(strings.ToLower(s.Value))
+	r[0] = unicode.ToUpper(r[0])
+	return &String{Value: string(r)}
+}
+
+// padArguments reads the (width, fill) arguments shared by the padding
+// methods. fill defaults to a single space.
+func padArguments(name string, args []Object) (int64, string, Object) {
+	if len(args) < 1 || len(args) > 2 {
+		return 0, "", newError(ARGUMENTERROR, "1 or 2", len(args))
+	}
+	width, ok := args[0].(*Integer)
+	if !ok {
+		return 0, "", newError(INPUTERROR, args[0].Type(), name)
+	}
+	fill := " "
+	if len(args) == 2 {
+		f, ok := args[1].(*String)
+		if !ok {
+			return 0, "", newError(INPUTERROR, args[1].Type(), name)
+		}
+		if len([]rune(f.Value)) != 1 {
+			return 0, "", newError(INLENERR, name, "1", strconv.Itoa(len([]rune(f.Value))))
+		}
+		fill = f.Value
+	}
+	return width.Value, fill, nil
+}
+
+func (s *String) PadLeft(args ...Object) Object {
+	return s.pad(&runtime{}, "pad_left", args)
+}
+
+func (s *String) PadRight(args ...Object) Object {
+	return s.pad(&runtime{}, "pad_right", args)
+}
+
+func (s *String) Center(args ...Object) Object {
+	return s.pad(&runtime{}, "center", args)
+}
+
+// pad implements pad_left, pad_right and center, checking the length of the
+// padded string against rt's limits before building it.
+func (s *String) pad(rt *runtime, name string, args []Object) Object {
+	width, fill, err := padArguments(name, args)
+	if err != nil {
+		return err
+	}
+	n := width - int64(len([]rune(s.Value)))
+	if n <= 0 {
+		return s
+	}
+	size := repeatedLength(fill, n)
+	if size <= math.MaxInt64-int64(len(s.Value)) {
+		size += int64(len(s.Value))
+	}
+	if err := rt.checkLength(STRING_OBJ, size); err != nil {
+		return err
+	}
+	switch name {
+	case "pad_left":
+		return &String{Value: strings.Repeat(fill, int(n)) + s.Value}
+	case "pad_right":
+		return &String{Value: s.Value + strings.Repeat(fill, int(n))}
+	}
+	left := n / 2
+	return &String{Value: strings.Repeat(fill, int(left)) + s.Value + strings.Repeat(fill, int(n-left))}
+}
+
+func (s *String) Repeat(args ...Object) Object {
+	return s.repeat(&runtime{}, args)
+}
+
+// repeat implements repeat and the * operator, checking the length of the
+// result against rt's limits before building it.
+func (s *String) repeat(rt *runtime, args []Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENTERROR, "1", len(args))
+	}
+	n, ok := args[0].(*Integer)
+	if !ok {
+		return newError(INPUTERROR, args[0].Type(), "repeat")
+	}
+	if n.Value < 0 {
+		return newError(INPUTERROR, n.Inspect(), "repeat")
+	}
+	if err := rt.checkLength(STRING_OBJ, repeatedLength(s.Value, n.Value)); err != nil {
+		return err
+	}
+	return &String{Value: strings.Repeat(s.Value, int(n.Value))}
+}
+
+// repeatedLength returns the length of s repeated n times, or MaxInt64 when
+// that overflows.
+func repeatedLength(s string, n int64) int64 {
+	if len(s) != 0 && n > math.MaxInt64/int64(len(s)) {
+		return math.MaxInt64
+	}
+	return int64(len(s)) * n
+}
+
+func (s *String) Lines(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	a := &Array{Members: []Object{}}
+	if s.Value == "" {
+		return a
+	}
+	text := strings.TrimSuffix(strings.Replace(s.Value, "\r\n", "\n", -1), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		a.Members = append(a.Members, &String{Value: line})
+	}
+	return a
+}
+
+func (s *String) Chars(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	a := &Array{Members: []Object{}}
+	for _, ch := range s.Value {
+		a.Members = append(a.Members, &String{Value: string(ch)})
+	}
+	return a
+}
+
+// allRunes reports whether s is non-empty and every rune satisfies f.
+func (s *String) allRunes(f func(rune) bool) bool {
+	if s.Value == "" {
+		return false
+	}
+	for _, ch := range s.Value {
+		if !f(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *String) IsDigit(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	return nativeBoolToBooleanObject(s.allRunes(unicode.IsDigit))
+}
+
+func (s *String) IsAlpha(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	return nativeBoolToBooleanObject(s.allRunes(unicode.IsLetter))
+}
+
+func (s *String) IsSpace(args ...Object) Object {
+	if len(args) != 0 {
+		return newError(ARGUMENTERROR, "0", len(args))
+	}
+	return nativeBoolToBooleanObject(s.allRunes(unicode.IsSpace))
+}
+
+// cutset returns the set of characters to trim. Unlike lstrip and rstrip,
+// which strip a repeating substring, the trim methods treat their argument as
+// a set of individual characters and default to whitespace.
+func cutset(name string, args []Object) (string, Object) {
+	if len(args) > 1 {
+		return "", newError(ARGUMENTERROR, "0 or 1", len(args))
+	}
+	if len(args) == 0 {
+		return " \t\n\r", nil
+	}
+	return stringArgument(name, args)
+}
+
+func (s *String) Trim(args ...Object) Object {
+	chars, err := cutset("trim", args)
+	if err != nil {
+		return err
+	}
+	return &String{Value: strings.Trim(s.Value, chars)}
+}
+
+func (s *String) TrimLeft(args ...Object) Object {
+	chars, err := cutset("trim_left", args)
+	if err != nil {
+		return err
+	}
+	return &String{Value: strings.TrimLeft(s.Value, chars)}
+}
+
+func (s *String) TrimRight(args ...Object) Object {
+	chars, err := cutset("trim_right", args)
+	if err != nil {
+		return err
+	}
+	return &String{Value: strings.TrimRight(s.Value, chars)}
+}
+
+func (s *String) Rfind(args ...Object) Object {
+	sub, err := stringArgument("rfind", args)
+	if err != nil {
+		return err
+	}
+	if sub == "" || s.Value == "" {
+		return NULL
+	}
+	i := strings.LastIndex(s.Value, sub)
+	if i < 0 {
+		return NULL
+	}
+	return &Integer{Value: int64(i)}
+}
+
+// Partition splits the string at the first occurrence of sep, returning
+// [before, sep, after]. If sep isn't found the result is [string, "", ""].
+func (s *String) Partition(args ...Object) Object {
+	sep, err := stringArgument("partition", args)
+	if err != nil {
+		return err
+	}
+	if sep == "" {
+		return newError(INPUTERROR, "empty separator", "partition")
+	}
+	i := strings.Index(s.Value, sep)
+	if i < 0 {
+		return &Array{Members: []Object{s, &String{}, &String{}}}
+	}
+	return &Array{Members: []Object{
+		&String{Value: s.Value[:i]},
+		&String{Value: sep},
+		&String{Value: s.Value[i+len(sep):]},
+	}}
+}
+
+// Format replaces {} and {n} placeholders with positional arguments and
+// {name} placeholders with the values of a trailing hash argument. Braces
+// are escaped by doubling them, e.g. {{ and }}.
+func (s *String) Format(args ...Object) Object {
+	positional := args
+	var named *Hash
+	if l := len(args); l > 0 {
+		if h, ok := args[l-1].(*Hash); ok {
+			named = h
+			positional = args[:l-1]
+		}
+	}
+
+	var out bytes.Buffer
+	next := 0
+	str := s.Value
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		if ch == '}' {
+			if i+1 < len(str) && str[i+1] == '}' {
+				i++
+			}
+			out.WriteByte(ch)
+			continue
+		}
+		if ch != '{' {
+			out.WriteByte(ch)
+			continue
+		}
+		if i+1 < len(str) && str[i+1] == '{' {
+			out.WriteByte(ch)
+			i++
+			continue
+		}
+		end := strings.IndexByte(str[i:], '}')
+		if end < 0 {
+			return newError(FORMATERROR, "unmatched '{' in format string")
+		}
+		field := strings.TrimSpace(str[i+1 : i+end])
+		i += end
+
+		var value Object
+		switch n, err := strconv.Atoi(field); {
+		case field == "":
+			if next >= len(positional) {
+				return newError(FORMATERROR, "not enough arguments for '{}'")
+			}
+			value = positional[next]
+			next++
+		case err == nil:
+			if n < 0 || n >= len(positional) {
+				return newError(INDEXERROR, n)
+			}
+			value = positional[n]
+		default:
+			if named == nil {
+				return newError(FORMATERROR, "no value for named field '"+field+"'")
+			}
+			pair, ok := named.Pairs[(&String{Value: field}).HashKey()]
+			if !ok {
+				return newError(FORMATERROR, "no value for named field '"+field+"'")
+			}
+			value = pair.Value
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &String{Value: out.String()}
+}
+
+func regexArgument(name string, args []Object) (*Regex, Object) {
+	if len(args) != 1 {
+		return nil, newError(ARGUMENTERROR, "1", len(args))
+	}
+	switch pattern := args[0].(type) {
+	case *Regex:
+		return pattern, nil
+	case *String:
+		re := newRegex(pattern.Value)
+		if re.Type() == ERROR_OBJ {
+			return nil, re
+		}
+		return re.(*Regex), nil
+	}
+	return nil, newError(INPUTERROR, args[0].Type(), name)
+}
+
+// Match reports whether the string contains a match of the given regex or
+// pattern string.
+func (s *String) Match(args ...Object) Object {
+	re, err := regexArgument("match", args)
+	if err != nil {
+		return err
+	}
+	return re.Match(s)
+}
+
+func (s *String) FindAll(args ...Object) Object {
+	re, err := regexArgument("find_all", args)
+	if err != nil {
+		return err
+	}
+	return re.FindAll(s)
+}
@@ -0,0 +1,243 @@
+package eval
+
+import (
+	"bytes"
+	"monkey/ast"
+	"sort"
+	"strconv"
+)
+
+// StructType is a named struct type declared with a struct statement. Calling
+// it constructs an instance; its methods are shared by every instance.
+type StructType struct {
+	Name    string
+	Fields  []*ast.StructField
+	Methods map[string]*Function
+	// Embeds is the type whose fields and methods this type inherits.
+	Embeds *StructType
+	// Scope is where the type was declared, used to evaluate field defaults
+	// and as the enclosing scope of its methods.
+	Scope *Scope
+}
+
+func (st *StructType) Inspect() string  { return "struct " + st.Name }
+func (st *StructType) Type() ObjectType { return STRUCT_TYPE_OBJ }
+
+// CallMethod calls a method of the type with an explicit receiver, e.g.
+// `Point.move(self, 1)`, which lets an overriding method call the method it
+// overrides.
+func (st *StructType) CallMethod(method string, args ...Object) Object {
+	fn, ok := st.lookupMethod(method)
+	if !ok {
+		return newError(NOMETHODERROR, method, st.Type())
+	}
+	if len(args) == 0 {
+		return newError(ARGUMENTERROR, strconv.Itoa(len(fn.Literal.Parameters)), 0)
+	}
+	receiver, ok := args[0].(*Struct)
+	if !ok || !receiver.typ.is(st) {
+		return newError(STRUCTERROR, st.Name+"."+method+" needs a "+st.Name+" receiver, got "+typeName(args[0]))
+	}
+	return receiver.callTypeMethod(fn, args[1:]...)
+}
+
+// is reports whether st is other or embeds it.
+func (st *StructType) is(other *StructType) bool {
+	for t := st; t != nil; t = t.Embeds {
+		if t == other {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldNames returns the names of the type's fields, embedded fields first.
+func (st *StructType) fieldNames() []string {
+	names := []string{}
+	if st.Embeds != nil {
+		names = st.Embeds.fieldNames()
+	}
+	for _, f := range st.Fields {
+		names = append(names, f.Name.Value)
+	}
+	return names
+}
+
+func (st *StructType) hasField(name string) bool {
+	for _, f := range st.fieldNames() {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupMethod finds a method on the type or, failing that, on the types it
+// embeds.
+func (st *StructType) lookupMethod(name string) (*Function, bool) {
+	for t := st; t != nil; t = t.Embeds {
+		if fn, ok := t.Methods[name]; ok {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// construct creates an instance from positional args, which fill the
+// embedded type's fields before the type's own.
+func (st *StructType) construct(args []Object) Object {
+	if n := len(st.fieldNames()); len(args) > n {
+		return newError(ARGUMENTERROR, "at most "+strconv.Itoa(n), len(args))
+	}
+	fields, err := st.initFields(args)
+	if err != nil {
+		return err
+	}
+	return &Struct{Scope: fields, methods: make(map[string]*Function), typ: st}
+}
+
+// initFields returns a scope holding the type's fields. The fields of an
+// embedded type live in the parent scope, so lookups and assignments follow
+// the embedding chain. Fields that aren't passed get their default, evaluated
+// for each instance so that instances don't share mutable defaults. A default
+// can refer to the fields before it.
+func (st *StructType) initFields(args []Object) (*Scope, Object) {
+	var embedded *Scope
+	defaults := NewScope(st.Scope)
+	if st.Embeds != nil {
+		n := len(st.Embeds.fieldNames())
+		if n > len(args) {
+			n = len(args)
+		}
+		var err Object
+		if embedded, err = st.Embeds.initFields(args[:n]); err != nil {
+			return nil, err
+		}
+		args = args[n:]
+		for _, name := range st.Embeds.fieldNames() {
+			v, _ := embedded.Get(name)
+			defaults.Set(name, v)
+		}
+	}
+	fields := NewScope(embedded)
+	for i, f := range st.Fields {
+		var value Object
+		switch {
+		case i < len(args):
+			value = args[i]
+		case f.Default != nil:
+			value = Eval(f.Default, defaults)
+			if value.Type() == ERROR_OBJ {
+				return nil, value
+			}
+		default:
+			return nil, newError(STRUCTERROR, "missing value for field '"+f.Name.Value+"' of "+st.Name)
+		}
+		defaults.Set(f.Name.Value, value)
+		fields.Set(f.Name.Value, value)
+	}
+	return fields, nil
+}
+
+func evalStructStatement(ss *ast.StructStatement, scope *Scope) Object {
+	st := &StructType{
+		Name:    ss.Name.Value,
+		Fields:  ss.Fields,
+		Methods: make(map[string]*Function),
+		Scope:   scope,
+	}
+	if ss.Embeds != nil {
+		obj := Eval(ss.Embeds, scope)
+		if obj.Type() == ERROR_OBJ {
+			return obj
+		}
+		embedded, ok := obj.(*StructType)
+		if !ok {
+			return newError(STRUCTERROR, st.Name+" can't embed "+string(obj.Type()))
+		}
+		for _, f := range st.Fields {
+			if embedded.hasField(f.Name.Value) {
+				return newError(STRUCTERROR, st.Name+" redeclares field '"+f.Name.Value+"' of "+embedded.Name)
+			}
+		}
+		st.Embeds = embedded
+	}
+	for _, m := range ss.Methods {
+		st.Methods[m.Name.Value] = &Function{Literal: m.Function, Scope: scope}
+	}
+	return scope.Set(ss.Name.Value, st)
+}
+
+// callTypeMethod calls a method of the instance's struct type with the
+// instance as the method's first parameter.
+func (s *Struct) callTypeMethod(fn *Function, args ...Object) Object {
+	if want := len(fn.Literal.Parameters) - 1; len(args) != want {
+		return newError(ARGUMENTERROR, strconv.Itoa(want), len(args))
+	}
+	return applyFunction(fn, append([]Object{s}, args...)...)
+}
+
+// setField assigns a field of the instance. Instances of a struct type only
+// have the fields the type declares.
+func (s *Struct) setField(name string, value Object) Object {
+	if s.typ != nil {
+		if !s.typ.hasField(name) {
+			return newError(STRUCTERROR, s.typ.Name+" has no field '"+name+"'")
+		}
+		// the field may belong to an embedded type
+		v, _ := s.Scope.Reset(name, value)
+		return v
+	}
+	return s.Scope.Set(name, value)
+}
+
+// fieldValues returns the struct's fields, including embedded ones.
+func (s *Struct) fieldValues() map[string]Object {
+	if s.typ == nil {
+		return s.Scope.bindings()
+	}
+	values := make(map[string]Object)
+	for _, name := range s.typ.fieldNames() {
+		values[name], _ = s.Scope.Get(name)
+	}
+	return values
+}
+
+// methodNames returns the names of the methods added with addm and those of
+// the struct's type and the types it embeds.
+func (s *Struct) methodNames() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for name := range s.methods {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for t := s.typ; t != nil; t = t.Embeds {
+		for name := range t.Methods {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Struct) inspectTyped() string {
+	var out bytes.Buffer
+	out.WriteString(s.typ.Name)
+	out.WriteString("{")
+	for i, name := range s.typ.fieldNames() {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(name)
+		out.WriteString(": ")
+		if v, ok := s.Scope.Get(name); ok {
+			out.WriteString(v.Inspect())
+		}
+	}
+	out.WriteString("}")
+	return out.String()
+}
@@ -0,0 +1,26 @@
+// Package format formats monkey source code.
+package format
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+// Source returns src in the canonical layout: one statement per line,
+// indented by two spaces in each block, with single spaces around operators
+// and after commas, and parentheses and semicolons only where they are
+// needed. Blank lines between statements are kept, as are blocks and lists
+// written on one line, or broken after their opening bracket. When src
+// doesn't parse, the parser's diagnostics are returned instead.
+func Source(src []byte) ([]byte, []*parser.Diagnostic) {
+	p := parser.New(lexer.New(string(src)), "")
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, p.Diagnostics()
+	}
+	out := newPrinter(p.Tokens()).statements(program.Statements, "")
+	if out != "" {
+		out += "\n"
+	}
+	return []byte(out), nil
+}
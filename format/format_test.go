@@ -0,0 +1,125 @@
+package format
+
+import (
+	"flag"
+	"io/ioutil"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1;x+2", "let x = 1\nx + 2\n"},
+		{"let a = [1,2];\na[0];\na;\n-a", "let a = [1, 2]\na[0]\na;\n-a\n"},
+		{"let h = {\"a\"->1}\nh[\"a\"]", "let h = {\"a\" -> 1}\nh[\"a\"]\n"},
+		{"let f = fn(x) { let y = x * 2; y }", "let f = fn(x) { let y = x * 2; y }\n"},
+		{"", ""},
+		// parentheses are kept only where precedence needs them
+		{"((1 + 2)) * (3 * 4) * (5 * 6)", "(1 + 2) * (3 * 4) * (5 * 6)\n"},
+		{"(1 * 2) + (3 * 4)", "1 * 2 + 3 * 4\n"},
+		{"-(a.b)[0]", "-a.b[0]\n"},
+		{"(fn() { 1 })()", "(fn() { 1 })()\n"},
+		// a semicolon is kept where the next line would continue the statement
+		{"let x = 1;(x)", "let x = 1\nx\n"},
+		{"let x = 1;(-x)", "let x = 1;\n-x\n"},
+		{"let x = 1;[1]", "let x = 1;\n[1]\n"},
+		// single blank lines between statements are kept
+		{"let x = 1\n\n\n\nlet y = 2\nx", "let x = 1\n\nlet y = 2\nx\n"},
+		// blocks and lists broken across lines stay broken, one item a line
+		{"if (x) {\nreturn; }", "if (x) {\n  return;\n}\n"},
+		{"puts(\n1, [\n2, 3], [4])", "puts(\n  1,\n  [\n    2,\n    3\n  ],\n  [4]\n)\n"},
+		{"struct P { x, y = 0; fn f(self) { self.x } }", "struct P { x, y = 0; fn f(self) { self.x } }\n"},
+		{"struct P {\nx,y\nfn f(self) {\n self.x } }", "struct P {\n  x, y\n  fn f(self) {\n    self.x\n  }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		out, errs := Source([]byte(tt.input))
+		if errs != nil {
+			t.Errorf("%q: unexpected parse errors %v", tt.input, errs)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, out)
+			continue
+		}
+		again, errs := Source(out)
+		if errs != nil || string(again) != string(out) {
+			t.Errorf("%q: formatting isn't idempotent: %q, %v", tt.input, again, errs)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	out, errs := Source([]byte("let = 5"))
+	if out != nil || len(errs) == 0 {
+		t.Errorf("expected parse errors, got %q, %v", out, errs)
+	}
+}
+
+// TestGolden formats the programs in parser/test_files and compares them with
+// the golden files in testdata. Run the tests with -update to rewrite them.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("..", "parser", "test_files")
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".my" {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		golden := filepath.Join("testdata", rel+".golden")
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, errs := Source(input)
+		if errs != nil {
+			t.Errorf("%s: unexpected parse errors %v", rel, errs)
+			return nil
+		}
+		if *update {
+			if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+				return err
+			}
+			return ioutil.WriteFile(golden, out, 0644)
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			return err
+		}
+		if string(out) != string(expected) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", rel, expected, out)
+		}
+		if again, _ := Source(out); string(again) != string(out) {
+			t.Errorf("%s: formatting isn't idempotent:\n%s", rel, again)
+		}
+		if before, after := tree(t, input), tree(t, out); before != after {
+			t.Errorf("%s: formatting changed the program from\n%s\nto\n%s", rel, before, after)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tree returns the statements of src as the parser sees them.
+func tree(t *testing.T, src []byte) string {
+	p := parser.New(lexer.New(string(src)), "")
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Errorf("parse errors: %v", p.Errors())
+	}
+	statements := []string{}
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt.String())
+	}
+	return strings.Join(statements, "\n")
+}
@@ -0,0 +1,466 @@
+package format
+
+import (
+	"monkey/ast"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+const indentation = "  "
+
+// atom is the precedence of expressions that never need parentheses, such
+// as literals and names.
+const atom = parser.INDEX + 1
+
+// printer prints a syntax tree in the canonical layout. The tokens the
+// program was parsed from tell it what the tree leaves out: where blank
+// lines were, and whether blocks and lists were broken over lines.
+type printer struct {
+	tokens []token.Token
+	// index maps the position of each token to its place in tokens
+	index map[[2]int]int
+}
+
+func newPrinter(tokens []token.Token) *printer {
+	p := &printer{tokens: tokens, index: make(map[[2]int]int)}
+	for i, tok := range tokens {
+		p.index[[2]int{tok.Line, tok.Column}] = i
+	}
+	return p
+}
+
+// next returns the token after tok.
+func (p *printer) next(tok token.Token) (token.Token, bool) {
+	i, ok := p.index[[2]int{tok.Line, tok.Column}]
+	if !ok || i+1 >= len(p.tokens) {
+		return token.Token{}, false
+	}
+	return p.tokens[i+1], true
+}
+
+// endLine returns the line tok ends on. Strings may span lines.
+func endLine(tok token.Token) int {
+	if tok.Type == token.STRING || tok.Type == token.ISTRING {
+		return tok.Line + strings.Count(tok.Literal, "\n")
+	}
+	return tok.Line
+}
+
+// blankBefore reports whether there is a blank line before tok.
+func (p *printer) blankBefore(tok token.Token) bool {
+	i, ok := p.index[[2]int{tok.Line, tok.Column}]
+	if !ok || i == 0 {
+		return false
+	}
+	return tok.Line-endLine(p.tokens[i-1]) > 1
+}
+
+// broken reports whether the source breaks the line after the bracket open,
+// which starts a block or list that the printer then prints over several
+// lines.
+func (p *printer) broken(open token.Token) bool {
+	next, ok := p.next(open)
+	return ok && next.Line > open.Line
+}
+
+// firstToken returns the token a statement starts with.
+func firstToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.IncludeStatement:
+		return stmt.Token
+	case *ast.ImportStatement:
+		return stmt.Token
+	case *ast.ExportStatement:
+		return stmt.Token
+	case *ast.StructStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+// separated reports whether a statement printed as next needs a semicolon
+// before it, so that it isn't parsed as part of prev: as a call, an index
+// or a subtraction.
+func separated(prev ast.Statement, next string) bool {
+	if _, ok := prev.(*ast.StructStatement); ok {
+		return false
+	}
+	if ret, ok := prev.(*ast.ReturnStatement); ok && ret.ReturnValue == nil {
+		return false
+	}
+	return next != "" && strings.ContainsAny(next[:1], "([-")
+}
+
+// statements prints statements one per line at indent, keeping single blank
+// lines between them.
+func (p *printer) statements(statements []ast.Statement, indent string) string {
+	var out strings.Builder
+	for i, stmt := range statements {
+		text := p.statement(stmt, indent)
+		if i > 0 {
+			if separated(statements[i-1], text) {
+				out.WriteString(";")
+			}
+			out.WriteString("\n")
+			if p.blankBefore(firstToken(stmt)) {
+				out.WriteString("\n")
+			}
+		}
+		out.WriteString(indent + text)
+	}
+	return out.String()
+}
+
+func (p *printer) statement(stmt ast.Statement, indent string) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return "let " + stmt.Name.Value + " = " + p.expression(stmt.Value, indent, parser.LOWEST)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			// without the semicolon what follows would be returned
+			return "return;"
+		}
+		return "return " + p.expression(stmt.ReturnValue, indent, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		return p.expression(stmt.Expression, indent, parser.LOWEST)
+	case *ast.IncludeStatement:
+		return "include " + p.expression(stmt.IncludePath, indent, parser.LOWEST)
+	case *ast.ImportStatement:
+		return p.importStatement(stmt)
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			return "export " + p.statement(stmt.Statement, indent)
+		}
+		names := []string{}
+		for _, name := range stmt.Names {
+			names = append(names, name.Value)
+		}
+		return "export " + strings.Join(names, ", ")
+	case *ast.StructStatement:
+		return p.structStatement(stmt, indent)
+	}
+	return stmt.String()
+}
+
+func (p *printer) importStatement(stmt *ast.ImportStatement) string {
+	// the path is written bare or quoted, as it was in the source
+	path := stmt.Path
+	if tok, ok := p.next(stmt.Token); !ok || tok.Type == token.STRING {
+		path = `"` + path + `"`
+	}
+	if len(stmt.Names) > 0 {
+		names := []string{}
+		for _, name := range stmt.Names {
+			names = append(names, name.Value)
+		}
+		return "from " + path + " import " + strings.Join(names, ", ")
+	}
+	out := "import " + path
+	if stmt.Alias != nil {
+		out += " as " + stmt.Alias.Value
+	}
+	return out
+}
+
+// structStatement prints a struct type. Fields written on one line stay on
+// one line, and members are kept in the order they were written.
+func (p *printer) structStatement(stmt *ast.StructStatement, indent string) string {
+	type member struct {
+		tok  token.Token
+		text string
+		// field is set for fields, which share lines
+		field bool
+	}
+	inner := indent + indentation
+	members := []member{}
+	for _, f := range stmt.Fields {
+		text := f.Name.Value
+		if f.Default != nil {
+			text += " = " + p.expression(f.Default, inner, parser.LOWEST)
+		}
+		members = append(members, member{f.Name.Token, text, true})
+	}
+	for _, m := range stmt.Methods {
+		text := "fn " + m.Name.Value + p.function(m.Function, inner)
+		members = append(members, member{m.Function.Token, text, false})
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i].tok, members[j].tok
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	head := "struct " + stmt.Name.Value
+	if stmt.Embeds != nil {
+		head += "(" + stmt.Embeds.Value + ")"
+	}
+	if len(members) == 0 {
+		return head + " {}"
+	}
+	// a line holds a method or the fields written on one line
+	lines := []string{}
+	starts := []token.Token{}
+	for i, m := range members {
+		if i > 0 && m.field && members[i-1].field && m.tok.Line == members[i-1].tok.Line {
+			lines[len(lines)-1] += ", " + m.text
+			continue
+		}
+		lines = append(lines, m.text)
+		starts = append(starts, m.tok)
+	}
+	open, _ := p.next(stmt.Name.Token)
+	if stmt.Embeds != nil {
+		open, _ = p.next(stmt.Embeds.Token)
+		open, _ = p.next(open)
+	}
+	if !p.broken(open) && singleLine(lines) {
+		return head + " { " + strings.Join(lines, "; ") + " }"
+	}
+	var out strings.Builder
+	out.WriteString(head + " {\n")
+	for i, line := range lines {
+		if i > 0 && p.blankBefore(starts[i]) {
+			out.WriteString("\n")
+		}
+		out.WriteString(inner + line + "\n")
+	}
+	out.WriteString(indent + "}")
+	return out.String()
+}
+
+func singleLine(texts []string) bool {
+	for _, text := range texts {
+		if strings.Contains(text, "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// block prints a block on one line if it was written on one line and holds
+// nothing that needs more, and otherwise one statement per line.
+func (p *printer) block(b *ast.BlockStatement, indent string) string {
+	if len(b.Statements) == 0 {
+		return "{}"
+	}
+	if !p.broken(b.Token) {
+		texts := []string{}
+		inline := true
+		for _, stmt := range b.Statements {
+			if _, ok := stmt.(*ast.StructStatement); ok {
+				inline = false
+			}
+			texts = append(texts, p.statement(stmt, indent))
+		}
+		if inline && singleLine(texts) {
+			out := texts[0]
+			for _, text := range texts[1:] {
+				if !strings.HasSuffix(out, ";") {
+					out += ";"
+				}
+				out += " " + text
+			}
+			return "{ " + out + " }"
+		}
+	}
+	return "{\n" + p.statements(b.Statements, indent+indentation) + "\n" + indent + "}"
+}
+
+// list prints the items of a literal or call between open and close, on one
+// line unless the source breaks the line after the opening bracket. items
+// prints the items at an indentation.
+func (p *printer) list(open token.Token, openText, closeText string, items func(indent string) []string, indent string) string {
+	if !p.broken(open) {
+		return openText + strings.Join(items(indent), ", ") + closeText
+	}
+	texts := items(indent + indentation)
+	if len(texts) == 0 {
+		return openText + closeText
+	}
+	inner := indent + indentation
+	return openText + "\n" + inner + strings.Join(texts, ",\n"+inner) + "\n" + indent + closeText
+}
+
+func (p *printer) expressions(es []ast.Expression) func(indent string) []string {
+	return func(indent string) []string {
+		texts := []string{}
+		for _, e := range es {
+			texts = append(texts, p.expression(e, indent, parser.LOWEST))
+		}
+		return texts
+	}
+}
+
+func (p *printer) pairs(keys []ast.Expression, pairs map[ast.Expression]ast.Expression) func(indent string) []string {
+	return func(indent string) []string {
+		texts := []string{}
+		for _, key := range keys {
+			texts = append(texts, p.expression(key, indent, parser.LOWEST)+" -> "+p.expression(pairs[key], indent, parser.LOWEST))
+		}
+		return texts
+	}
+}
+
+// function prints a function literal from its parameters on.
+func (p *printer) function(fn *ast.FunctionLiteral, indent string) string {
+	params := []string{}
+	for _, param := range fn.Parameters {
+		params = append(params, param.String())
+	}
+	return "(" + strings.Join(params, ", ") + ") " + p.block(fn.Body, indent)
+}
+
+// precedence returns how tightly e binds, as the parser's precedences do.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		// the operators that are words are keywords
+		t := token.LookupIdent(e.Operator)
+		if t == token.IDENT {
+			t = token.TokenType(e.Operator)
+		}
+		return parser.Precedence(t)
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.SliceExpression:
+		return parser.SLICE
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.MethodCallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	}
+	return atom
+}
+
+// expression prints e, in parentheses unless it binds at least as tightly
+// as min. Expressions ending in a block are parenthesized when they are
+// operands, which the parser doesn't need but readers do.
+func (p *printer) expression(e ast.Expression, indent string, min int) string {
+	text := p.bare(e, indent)
+	if precedence(e) < min || min > parser.LOWEST && endsInBlock(e) {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func endsInBlock(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.FunctionLiteral, *ast.IfExpression, *ast.DoLoop, *ast.SelectExpression:
+		return true
+	}
+	return false
+}
+
+func (p *printer) bare(e ast.Expression, indent string) string {
+	switch e := e.(type) {
+	case nil:
+		return ""
+	case *ast.InfixExpression:
+		prec := precedence(e)
+		// operators group to the left
+		return p.expression(e.Left, indent, prec) + " " + e.Operator + " " + p.expression(e.Right, indent, prec+1)
+	case *ast.PrefixExpression:
+		return e.Operator + p.expression(e.Right, indent, parser.PREFIX)
+	case *ast.AssignExpression:
+		target := e.Name.Value
+		if e.Object != nil {
+			target = p.expression(e.Object, indent, parser.CALL) + "." + target
+		}
+		return target + " = " + p.expression(e.Value, indent, parser.LOWEST)
+	case *ast.SliceExpression:
+		start := ""
+		// a slice written without a start is given a start of 0
+		if lit, ok := e.StartIndex.(*ast.IntegerLiteral); !ok || lit.Token.Line > 0 {
+			start = p.expression(e.StartIndex, indent, parser.SLICE)
+		}
+		return start + ":" + p.expression(e.EndIndex, indent, parser.LOWEST)
+	case *ast.CallExpression:
+		return p.expression(e.Function, indent, parser.CALL) + p.list(e.Token, "(", ")", p.expressions(e.Arguments), indent)
+	case *ast.MethodCallExpression:
+		out := p.expression(e.Object, indent, parser.CALL) + "."
+		if call, ok := e.Call.(*ast.CallExpression); ok {
+			return out + call.Function.String() + p.list(call.Token, "(", ")", p.expressions(call.Arguments), indent)
+		}
+		return out + e.Call.String()
+	case *ast.IndexExpression:
+		return p.expression(e.Left, indent, parser.CALL) + "[" + p.expression(e.Index, indent, parser.LOWEST) + "]"
+	case *ast.FunctionLiteral:
+		return "fn" + p.function(e, indent)
+	case *ast.IfExpression:
+		out := "if (" + p.expression(e.Condition, indent, parser.LOWEST) + ") " + p.block(e.Consequence, indent)
+		if e.Alternative != nil {
+			out += " else " + p.block(e.Alternative, indent)
+		}
+		return out
+	case *ast.DoLoop:
+		return "do " + p.block(e.Block, indent)
+	case *ast.SelectExpression:
+		return p.selectExpression(e, indent)
+	case *ast.ArrayLiteral:
+		return p.list(e.Token, "[", "]", p.expressions(e.Members), indent)
+	case *ast.SetLiteral:
+		return p.list(e.Token, "{", "}", p.expressions(e.Members), indent)
+	case *ast.HashLiteral:
+		return p.list(e.Token, "{", "}", p.pairs(e.Order, e.Pairs), indent)
+	case *ast.StructLiteral:
+		open, _ := p.next(e.Token)
+		return "struct" + p.list(open, "(", ")", p.pairs(e.Order, e.Pairs), indent)
+	case *ast.InterpolatedString:
+		return p.interpolatedString(e, indent)
+	}
+	return e.String()
+}
+
+func (p *printer) selectExpression(e *ast.SelectExpression, indent string) string {
+	inner := indent + indentation
+	cases := []string{}
+	for _, c := range e.Cases {
+		text := "case " + p.expression(c.Call, inner, parser.LOWEST)
+		if c.Binding != nil {
+			text += " as " + c.Binding.Value
+		}
+		cases = append(cases, text+" "+p.block(c.Body, inner))
+	}
+	if e.Default != nil {
+		cases = append(cases, "default "+p.block(e.Default, inner))
+	}
+	if len(cases) == 0 {
+		return "select {}"
+	}
+	open, _ := p.next(e.Token)
+	if !p.broken(open) && singleLine(cases) {
+		return "select { " + strings.Join(cases, " ") + " }"
+	}
+	return "select {\n" + inner + strings.Join(cases, "\n"+inner) + "\n" + indent + "}"
+}
+
+// interpolatedString prints an interpolated string with the expressions in
+// it printed in place of their placeholders.
+func (p *printer) interpolatedString(e *ast.InterpolatedString, indent string) string {
+	var out strings.Builder
+	out.WriteString("'")
+	rest := e.Value
+	for key := byte('0'); ; key++ {
+		expr, ok := e.ExprMap[key]
+		placeholder := "{" + string(key) + "}"
+		i := strings.Index(rest, placeholder)
+		if !ok || i < 0 {
+			break
+		}
+		out.WriteString(rest[:i])
+		out.WriteString("{" + p.expression(expr, indent, parser.LOWEST) + "}")
+		rest = rest[i+len(placeholder):]
+	}
+	out.WriteString(rest)
+	out.WriteString("'")
+	return out.String()
+}
@@ -11,15 +11,22 @@ type Lexer struct {
 	ch           byte
 	position     int
 	readPosition int
+	// line is the line of ch, which starts at lineStart in input
+	line      int
+	lineStart int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -56,30 +63,40 @@ var tokenMap = map[byte]token.TokenType{
 	'>': token.GT,
 	':': token.COLON,
 	'%': token.MOD,
+	'|': token.PIPE,
+	'&': token.AMP,
 }
 
+// NextToken returns the next token, with the line and column it starts at.
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
 	l.skipWhitespace()
+	line, column := l.line, l.position-l.lineStart+1
+	tok := l.nextToken()
+	tok.Line, tok.Column = line, column
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 	if t, ok := tokenMap[l.ch]; ok {
 		switch t {
 		case token.EQ:
 			if l.peekChar() == '=' {
-				tok = token.Token{token.EQ, string(l.ch) + string(l.ch)}
+				tok = token.Token{Type: token.EQ, Literal: string(l.ch) + string(l.ch)}
 				l.readChar()
 			} else {
 				tok = newToken(token.ASSIGN, l.ch)
 			}
 		case token.MINUS:
 			if l.peekChar() == '>' {
-				tok = token.Token{token.ARROW, string(l.ch) + string(l.peekChar())}
+				tok = token.Token{Type: token.ARROW, Literal: string(l.ch) + string(l.peekChar())}
 				l.readChar()
 			} else {
 				tok = newToken(token.MINUS, l.ch)
 			}
 		case token.BANG:
 			if l.peekChar() == '=' {
-				tok = token.Token{token.NEQ, string(l.ch) + string(l.peekChar())}
+				tok = token.Token{Type: token.NEQ, Literal: string(l.ch) + string(l.peekChar())}
 				l.readChar()
 			} else {
 				tok = newToken(token.BANG, l.ch)
@@ -109,20 +126,26 @@ func (l *Lexer) readBytesToken() token.Token {
 		tok.Literal = l.readNumber()
 		return tok
 	case isQuote(l.ch):
+		start := l.position
 		if s, err := l.readString(); err == nil {
 			tok.Type = token.STRING
 			tok.Literal = s
 			return tok
 		}
+		// an unterminated string runs to the end of the input
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[start:]}
 	case isSingleQuote(l.ch):
+		start := l.position
 		if s, err := l.readInterpString(); err == nil {
 			tok.Type = token.ISTRING
 			tok.Literal = s
 			return tok
 		}
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[start:]}
 	}
+	tok = newToken(token.ILLEGAL, l.ch)
 	l.readChar()
-	return newToken(token.ILLEGAL, l.ch)
+	return tok
 }
 
 func newToken(tokenType token.TokenType, ch byte) token.Token {
@@ -147,6 +170,7 @@ func (l *Lexer) readString() (string, error) {
 
 func (l *Lexer) readInterpString() (string, error) {
 	start := l.position + 1
+	line, lineStart := l.line, l.lineStart
 	var out bytes.Buffer
 	pos := "0"[0]
 	for {
@@ -162,20 +186,23 @@ func (l *Lexer) readInterpString() (string, error) {
 		if l.ch == 123 {
 			if l.peekChar() != 125 {
 				out.WriteByte(l.ch)
-				for l.ch != 125 || l.ch == 0 {
+				for l.ch != 125 && l.ch != 0 {
 					l.readChar()
 				}
-				if l.ch != 0 {
-					out.WriteByte(pos)
-					pos++
+				if l.ch == 0 {
+					return "", errors.New("")
 				}
+				out.WriteByte(pos)
+				pos++
 			}
 		}
 		out.WriteByte(l.ch)
 	}
+	// the string's contents are lexed again for the expressions in it
 	l.position = start - 1
 	l.readPosition = start
 	l.ch = l.input[start]
+	l.line, l.lineStart = line, lineStart
 	return out.String(), nil
 }
 
@@ -184,6 +211,9 @@ func (l *Lexer) NextInterpToken() token.Token {
 	for {
 		if l.ch == '{' {
 			if l.peekChar() == '}' {
+				// empty braces are part of the text
+				l.readChar()
+				l.readChar()
 				continue
 			}
 			tok = newToken(token.LBRACE, l.ch)
@@ -37,6 +37,7 @@ x and y
 x or y
 struct
 do
+a | b & c
 `
 
 	tests := []struct {
@@ -149,6 +150,11 @@ do
 		{token.IDENT, "y"},
 		{token.STRUCT, "struct"},
 		{token.DO, "do"},
+		{token.IDENT, "a"},
+		{token.PIPE, "|"},
+		{token.IDENT, "b"},
+		{token.AMP, "&"},
+		{token.IDENT, "c"},
 		{token.EOF, ""},
 	}
 
@@ -167,3 +173,59 @@ do
 	}
 
 }
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		// empty braces are text, not an expression
+		{`'{}'`, []token.TokenType{token.ISTRING, token.ISTRING, token.EOF}},
+		{`'a {x} b'`, []token.TokenType{token.ISTRING, token.LBRACE, token.IDENT, token.RBRACE, token.ISTRING, token.EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		got := []token.TokenType{l.NextToken().Type}
+		// the parser reads a token ahead of the string before it asks for
+		// the tokens of the expressions in it
+		l.NextToken()
+		for {
+			tok := l.NextInterpToken()
+			got = append(got, tok.Type)
+			if tok.Type != token.LBRACE {
+				break
+			}
+			for tok = l.NextToken(); tok.Type != token.RBRACE && tok.Type != token.EOF; tok = l.NextToken() {
+				got = append(got, tok.Type)
+			}
+			got = append(got, tok.Type)
+		}
+		got = append(got, l.NextToken().Type)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	inputs := []string{`'a {x`, `'a {x}`, `'a {`, `"a`}
+
+	for _, input := range inputs {
+		l := New(input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != input {
+			t.Errorf("%s: expected ILLEGAL %q, got %s %q", input, input, tok.Type, tok.Literal)
+		}
+		if tok = l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%s: expected EOF, got %s %q", input, tok.Type, tok.Literal)
+		}
+	}
+}
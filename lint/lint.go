@@ -0,0 +1,502 @@
+// Package lint finds likely mistakes in monkey programs without running them:
+// names that are never defined, variables and parameters that are never used,
+// assignments to names that were never declared, code that can't be reached
+// and calls with the wrong number of arguments.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/eval"
+	"monkey/parser"
+	"monkey/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Problem is a likely mistake found in a program.
+type Problem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// Declaration is where a name is bound.
+type Declaration struct {
+	Name string
+	// Kind is let, struct, parameter, field, include, import or, for names
+	// bound without being declared in the program, predeclared.
+	Kind string
+	// Token is the name where it is declared; it is zero for predeclared
+	// names.
+	Token token.Token
+	// Value is the expression a let statement first binds the name to.
+	Value ast.Expression
+	// Node is the statement or function literal that declares the name.
+	Node ast.Node
+}
+
+// Reference is a name in a program and the declaration it resolves to.
+// Declarations refer to themselves.
+type Reference struct {
+	Use  token.Token
+	Decl Declaration
+}
+
+// predeclared are bound in every program run by the monkey command
+var predeclared = []string{"args"}
+
+// decl is a name bound in a scope.
+type decl struct {
+	name  string
+	tok   token.Token
+	used  bool
+	param bool
+	kind  string
+	value ast.Expression
+	node  ast.Node
+	// fn is the function the name is bound to, if it is bound once to a
+	// function literal, so that calls to it can be checked
+	fn *ast.FunctionLiteral
+	// unchecked is set for names that needn't be used
+	unchecked bool
+}
+
+// scope holds the names bound by a program, a function or a block that has a
+// scope of its own at run time.
+type scope struct {
+	parent *scope
+	decls  map[string]*decl
+	// open is set when a directory module is included, which binds names
+	// that aren't known here
+	open bool
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, decls: make(map[string]*decl)}
+}
+
+func (s *scope) lookup(name string) (*decl, bool) {
+	for ; s != nil; s = s.parent {
+		if d, ok := s.decls[name]; ok {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// isOpen reports whether names not declared in s may still be bound.
+func (s *scope) isOpen() bool {
+	for ; s != nil; s = s.parent {
+		if s.open {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	problems   []Problem
+	references []Reference
+}
+
+// resolve records that tok refers to d.
+func (l *linter) resolve(tok token.Token, d *decl) {
+	l.references = append(l.references, Reference{Use: tok, Decl: Declaration{
+		Name: d.name, Kind: d.kind, Token: d.tok, Value: d.value, Node: d.node,
+	}})
+}
+
+// bind declares d in s, and records the declaration as a reference to
+// itself.
+func (l *linter) bind(s *scope, d *decl) {
+	s.decls[d.name] = d
+	if d.tok.Line > 0 {
+		l.resolve(d.tok, d)
+	}
+}
+
+func (l *linter) report(tok token.Token, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// Program returns the problems found in program, ordered by position.
+func Program(program *ast.Program) []Problem {
+	l := check(program)
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i], l.problems[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.problems
+}
+
+// References returns the names in program that resolve to a declaration,
+// ordered by position. Names that can't be resolved, such as builtins,
+// fields and methods, are left out.
+func References(program *ast.Program) []Reference {
+	l := check(program)
+	sort.SliceStable(l.references, func(i, j int) bool {
+		a, b := l.references[i].Use, l.references[j].Use
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.references
+}
+
+func check(program *ast.Program) *linter {
+	l := &linter{}
+	global := newScope(nil)
+	for _, name := range predeclared {
+		global.decls[name] = &decl{name: name, kind: "predeclared", unchecked: true}
+	}
+	for _, name := range eval.BuiltinModuleNames() {
+		global.decls[name] = &decl{name: name, kind: "predeclared", unchecked: true}
+	}
+	// globals may be used by the files that include them, so unlike the
+	// names bound in functions they aren't checked for use
+	l.declare(global, program.Statements)
+	l.statements(global, program.Statements)
+	return l
+}
+
+// declare binds the names statements declare in s before any are resolved,
+// since a function can refer to names bound after it.
+func (l *linter) declare(s *scope, statements []ast.Statement) {
+	bind := func(id *ast.Identifier, kind string, value ast.Expression, node ast.Node) {
+		if d, ok := s.decls[id.Value]; ok {
+			// bound more than once, so calls to it can't be checked
+			d.fn = nil
+			l.resolve(id.Token, d)
+			return
+		}
+		d := &decl{name: id.Value, tok: id.Token, kind: kind, value: value, node: node}
+		d.fn, _ = value.(*ast.FunctionLiteral)
+		l.bind(s, d)
+	}
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			bind(stmt.Name, "let", stmt.Value, stmt)
+			l.declareNested(s, stmt.Value)
+		case *ast.ExportStatement:
+			if stmt.Statement != nil {
+				bind(stmt.Statement.Name, "let", stmt.Statement.Value, stmt)
+				l.declareNested(s, stmt.Statement.Value)
+			}
+		case *ast.StructStatement:
+			bind(stmt.Name, "struct", nil, stmt)
+		case *ast.IncludeStatement:
+			name := stmt.IncludePath.String()
+			if _, _, isDir, err := parser.ResolveModule(stmt.Dir, stmt.IncludePaths, name); err == nil && isDir {
+				s.open = true
+				continue
+			}
+			l.bind(s, &decl{name: name, tok: start(stmt.IncludePath), kind: "include", node: stmt, unchecked: true})
+		case *ast.ImportStatement:
+			if len(stmt.Names) == 0 {
+				tok := stmt.Token
+				if stmt.Alias != nil {
+					tok = stmt.Alias.Token
+				}
+				l.bind(s, &decl{name: stmt.Binding(), tok: tok, kind: "import", node: stmt, unchecked: true})
+			}
+			for _, id := range stmt.Names {
+				l.bind(s, &decl{name: id.Value, tok: id.Token, kind: "import", node: stmt, unchecked: true})
+			}
+		case *ast.ExpressionStatement:
+			l.declareNested(s, stmt.Expression)
+		}
+	}
+}
+
+// declareNested declares the names bound in the blocks of an if expression,
+// which share the scope they are in.
+func (l *linter) declareNested(s *scope, e ast.Expression) {
+	if ie, ok := e.(*ast.IfExpression); ok {
+		l.declare(s, ie.Consequence.Statements)
+		if ie.Alternative != nil {
+			l.declare(s, ie.Alternative.Statements)
+		}
+	}
+}
+
+// statements resolves the names used by statements, reporting any that
+// follow a return or break.
+func (l *linter) statements(s *scope, statements []ast.Statement) {
+	for i, stmt := range statements {
+		l.statement(s, stmt)
+		if terminates(stmt) && i+1 < len(statements) {
+			l.report(start(statements[i+1]), "unreachable code")
+		}
+	}
+}
+
+// terminates reports whether the statements after stmt can't run.
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		_, ok := stmt.Expression.(*ast.BreakExpression)
+		return ok
+	}
+	return false
+}
+
+// start returns the token a node starts at. Infix, call and index
+// expressions hold the token of their operator, so theirs is found on the
+// left.
+func start(n ast.Node) token.Token {
+	switch n := n.(type) {
+	case *ast.ExpressionStatement:
+		return start(n.Expression)
+	case *ast.InfixExpression:
+		return start(n.Left)
+	case *ast.CallExpression:
+		return start(n.Function)
+	case *ast.MethodCallExpression:
+		return start(n.Object)
+	case *ast.IndexExpression:
+		return start(n.Left)
+	case *ast.AssignExpression:
+		if n.Object != nil {
+			return start(n.Object)
+		}
+		return n.Name.Token
+	}
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && !v.IsNil() {
+		if f := v.Elem().FieldByName("Token"); f.IsValid() {
+			if tok, ok := f.Interface().(token.Token); ok {
+				return tok
+			}
+		}
+	}
+	return token.Token{}
+}
+
+func (l *linter) statement(s *scope, stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.expression(s, stmt.Value)
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			l.expression(s, stmt.Statement.Value)
+		}
+		for _, id := range stmt.Names {
+			l.use(s, id)
+		}
+	case *ast.ReturnStatement:
+		l.expression(s, stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		l.expression(s, stmt.Expression)
+	case *ast.StructStatement:
+		l.structStatement(s, stmt)
+	}
+}
+
+func (l *linter) structStatement(s *scope, stmt *ast.StructStatement) {
+	if stmt.Embeds != nil {
+		l.use(s, stmt.Embeds)
+	}
+	// a default can refer to the fields before it
+	defaults := newScope(s)
+	defaults.open = stmt.Embeds != nil
+	for _, f := range stmt.Fields {
+		l.expression(defaults, f.Default)
+		l.bind(defaults, &decl{name: f.Name.Value, tok: f.Name.Token, kind: "field", node: stmt, unchecked: true})
+	}
+	for _, m := range stmt.Methods {
+		l.function(s, m.Function, true)
+	}
+}
+
+// use resolves a name used as a value.
+func (l *linter) use(s *scope, id *ast.Identifier) {
+	if d, ok := s.lookup(id.Value); ok {
+		d.used = true
+		l.resolve(id.Token, d)
+		return
+	}
+	if s.isOpen() {
+		return
+	}
+	if _, ok := eval.BuiltinArity(id.Value); ok {
+		l.report(id.Token, "builtin %s can only be called", id.Value)
+		return
+	}
+	l.report(id.Token, "undefined: %s", id.Value)
+}
+
+func (l *linter) expressions(s *scope, es []ast.Expression) {
+	for _, e := range es {
+		l.expression(s, e)
+	}
+}
+
+func (l *linter) expression(s *scope, e ast.Expression) {
+	switch e := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		l.use(s, e)
+	case *ast.PrefixExpression:
+		l.expression(s, e.Right)
+	case *ast.InfixExpression:
+		l.expression(s, e.Left)
+		l.expression(s, e.Right)
+	case *ast.AssignExpression:
+		if e.Object != nil {
+			l.expression(s, e.Object)
+		} else if d, ok := s.lookup(e.Name.Value); ok {
+			// a reassigned function may take different arguments
+			d.fn = nil
+			l.resolve(e.Name.Token, d)
+		} else if !s.isOpen() {
+			l.report(e.Name.Token, "assignment to undeclared name %s; use let to declare it", e.Name.Value)
+		}
+		l.expression(s, e.Value)
+	case *ast.IfExpression:
+		l.expression(s, e.Condition)
+		l.statements(s, e.Consequence.Statements)
+		if e.Alternative != nil {
+			l.statements(s, e.Alternative.Statements)
+		}
+	case *ast.FunctionLiteral:
+		l.function(s, e, false)
+	case *ast.CallExpression:
+		l.call(s, e)
+	case *ast.MethodCallExpression:
+		l.expression(s, e.Object)
+		// the name after the dot is a method or field, not a variable
+		if call, ok := e.Call.(*ast.CallExpression); ok {
+			l.expressions(s, call.Arguments)
+		}
+	case *ast.IndexExpression:
+		l.expression(s, e.Left)
+		l.expression(s, e.Index)
+	case *ast.SliceExpression:
+		l.expression(s, e.StartIndex)
+		l.expression(s, e.EndIndex)
+	case *ast.ArrayLiteral:
+		l.expressions(s, e.Members)
+	case *ast.SetLiteral:
+		l.expressions(s, e.Members)
+	case *ast.HashLiteral:
+		for _, key := range e.Order {
+			l.expression(s, key)
+			l.expression(s, e.Pairs[key])
+		}
+	case *ast.StructLiteral:
+		// keys are field names
+		for _, key := range e.Order {
+			l.expression(s, e.Pairs[key])
+		}
+	case *ast.InterpolatedString:
+		for i := byte('0'); ; i++ {
+			expr, ok := e.ExprMap[i]
+			if !ok {
+				break
+			}
+			l.expression(s, expr)
+		}
+	case *ast.DoLoop:
+		l.block(s, e.Block)
+	case *ast.SelectExpression:
+		for _, c := range e.Cases {
+			l.expression(s, c.Call)
+			body := newScope(s)
+			if c.Binding != nil {
+				l.bind(body, &decl{name: c.Binding.Value, tok: c.Binding.Token, kind: "let", node: e})
+			}
+			l.declare(body, c.Body.Statements)
+			l.statements(body, c.Body.Statements)
+			l.unused(body)
+		}
+		if e.Default != nil {
+			l.block(s, e.Default)
+		}
+	}
+}
+
+// block checks a block that runs in a scope of its own.
+func (l *linter) block(s *scope, b *ast.BlockStatement) {
+	inner := newScope(s)
+	l.declare(inner, b.Statements)
+	l.statements(inner, b.Statements)
+	l.unused(inner)
+}
+
+// function checks a function literal. The first parameter of a struct
+// method is its receiver, which needn't be used.
+func (l *linter) function(s *scope, fn *ast.FunctionLiteral, method bool) {
+	inner := newScope(s)
+	// functions added to structs with addm refer to their struct as self
+	inner.decls["self"] = &decl{name: "self", kind: "predeclared", unchecked: true}
+	for i, p := range fn.Parameters {
+		id, ok := p.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		l.bind(inner, &decl{name: id.Value, tok: id.Token, kind: "parameter", node: fn, param: true, unchecked: method && i == 0})
+	}
+	if fn.Body != nil {
+		l.declare(inner, fn.Body.Statements)
+		l.statements(inner, fn.Body.Statements)
+	}
+	l.unused(inner)
+}
+
+// unused reports the names in s that are never used.
+func (l *linter) unused(s *scope) {
+	decls := []*decl{}
+	for _, d := range s.decls {
+		if !d.used && !d.unchecked && !strings.HasPrefix(d.name, "_") {
+			decls = append(decls, d)
+		}
+	}
+	for _, d := range decls {
+		if d.param {
+			l.report(d.tok, "parameter %s is never used", d.name)
+		} else {
+			l.report(d.tok, "%s is declared but never used", d.name)
+		}
+	}
+}
+
+// call checks a call expression, and the number of arguments passed to
+// functions whose parameters are known.
+func (l *linter) call(s *scope, call *ast.CallExpression) {
+	l.expressions(s, call.Arguments)
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		l.expression(s, call.Function)
+		return
+	}
+	if d, ok := s.lookup(id.Value); ok {
+		d.used = true
+		l.resolve(id.Token, d)
+		if d.fn != nil && len(d.fn.Parameters) != len(call.Arguments) {
+			l.report(id.Token, "%s takes %s, called with %d", id.Value, arguments(len(d.fn.Parameters)), len(call.Arguments))
+		}
+		return
+	}
+	if arity, ok := eval.BuiltinArity(id.Value); ok {
+		if arity != eval.Variadic && arity != len(call.Arguments) {
+			l.report(id.Token, "%s takes %s, called with %d", id.Value, arguments(arity), len(call.Arguments))
+		}
+		return
+	}
+	if !s.isOpen() {
+		l.report(id.Token, "undefined: %s", id.Value)
+	}
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
@@ -0,0 +1,124 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; puts(x)`, nil},
+		{`puts(y)`, []string{"1:6: undefined: y"}},
+		{`let f = fn() { g() }; let g = fn() { 1 }; f()`, nil},
+		{`let f = fn(a, b) { a }`, []string{"1:15: parameter b is never used"}},
+		{`let f = fn(a, _b) { let c = a; 1 }`, []string{"1:25: c is declared but never used"}},
+		{`let f = fn() { total = 1 }`, []string{"1:16: assignment to undeclared name total; use let to declare it"}},
+		{`let total = 0; let f = fn() { total = total + 1 }`, nil},
+		{"let f = fn() {\n  return 1\n  puts(2)\n}", []string{"3:3: unreachable code"}},
+		{"do {\n  break\n  puts(1)\n}", []string{"3:3: unreachable code"}},
+		{`let add = fn(a, b) { a + b }; add(1)`, []string{"1:31: add takes 2 arguments, called with 1"}},
+		{`len("a", "b")`, []string{"1:1: len takes 1 argument, called with 2"}},
+		{`let l = len`, []string{"1:9: builtin len can only be called"}},
+		{`let f = fn(a) { a }; f = fn(a, b) { a + b }; f(1, 2)`, nil},
+		{`puts(json.stringify(args))`, nil},
+		{`let p = struct(a -> 1); p.a = p.b + 1; p.go(2)`, nil},
+		{"struct Point {\n  x, y = x\n  fn norm(self) { 1 }\n}\nPoint(1).norm()", nil},
+		{"struct Bad {\n  x = y\n}", []string{"2:7: undefined: y"}},
+		{`let f = fn() { if (true) { let a = 1 }; a }; f()`, nil},
+		{`let f = fn() { do { let a = 1; puts(a); break }; a }; f()`, []string{"1:50: undefined: a"}},
+		{`let c = chan(); select { case c.recv() as v { v } }`, nil},
+		{`import "a/mod" as m; from "b" import c; puts(m.x + c)`, nil},
+		{`let s = 'sum {a + 1}'`, []string{"1:15: undefined: a"}},
+		{`let h = {"k" -> v}`, []string{"1:17: undefined: v"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input), "")
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%s: parser errors: %v", tt.input, p.Errors())
+			continue
+		}
+		got := []string{}
+		for _, problem := range Program(program) {
+			got = append(got, problem.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	wd, _ := os.Getwd()
+	dir := filepath.Join(wd, "..", "parser", "test_files")
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// a file module is bound to its name
+		{"include module\nputs(module.a)", nil},
+		// a directory module binds names that aren't known
+		{"include sub_package\nputs(anything)", nil},
+		{"puts(anything)", []string{"1:6: undefined: anything"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input), dir)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%s: parser errors: %v", tt.input, p.Errors())
+			continue
+		}
+		got := []string{}
+		for _, problem := range Program(program) {
+			got = append(got, problem.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	input := "let f = fn(a) { a + b }\nlet b = 1\nf(b)\nstruct P { x, y = x }\nputs(args, json)"
+	expected := []string{
+		"1:5 f let 1:5",
+		"1:12 a parameter 1:12",
+		"1:17 a parameter 1:12",
+		"1:21 b let 2:5",
+		"2:5 b let 2:5",
+		"3:1 f let 1:5",
+		"3:3 b let 2:5",
+		"4:8 P struct 4:8",
+		"4:12 x field 4:12",
+		"4:15 y field 4:15",
+		"4:19 x field 4:12",
+		"5:6 args predeclared 0:0",
+		"5:12 json predeclared 0:0",
+	}
+	p := parser.New(lexer.New(input), "")
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	got := []string{}
+	for _, ref := range References(program) {
+		got = append(got, fmt.Sprintf("%d:%d %s %s %d:%d", ref.Use.Line, ref.Use.Column, ref.Decl.Name, ref.Decl.Kind, ref.Decl.Token.Line, ref.Decl.Token.Column))
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if let, ok := References(program)[0].Decl.Value.(*ast.FunctionLiteral); !ok || len(let.Parameters) != 1 {
+		t.Errorf("expected f to be bound to its function literal")
+	}
+}
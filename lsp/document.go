@@ -0,0 +1,234 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"monkey/token"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// document is an open file, analyzed whenever its text changes.
+type document struct {
+	uri string
+	// dir is where the file's modules are found first
+	dir          string
+	includePaths []string
+	lines        []string
+	tokens       []token.Token
+	program      *ast.Program
+	parseErrors  []*parser.Diagnostic
+	problems     []lint.Problem
+	references   []lint.Reference
+}
+
+func newDocument(uri, text string, includePaths []string) *document {
+	d := &document{uri: uri, dir: ".", includePaths: includePaths, lines: strings.Split(text, "\n")}
+	if path, ok := uriPath(uri); ok {
+		d.dir = filepath.Dir(path)
+	}
+	l := lexer.New(text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	p := parser.New(lexer.New(text), d.dir)
+	p.SetIncludePaths(includePaths)
+	d.program = p.ParseProgram()
+	d.parseErrors = p.Diagnostics()
+	// the statements that failed to parse are left out, so names can
+	// still be resolved in the rest of the file
+	d.references = lint.References(d.program)
+	if len(d.parseErrors) == 0 {
+		d.problems = lint.Program(d.program)
+	}
+	return d
+}
+
+func uriPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// position converts a line and byte column, both counted from 1, to a
+// position in the document.
+func (d *document) position(line, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(d.lines) {
+		line = len(d.lines)
+		column = len(d.lines[line-1]) + 1
+	}
+	text := d.lines[line-1]
+	n := column - 1
+	if n < 0 {
+		n = 0
+	} else if n > len(text) {
+		n = len(text)
+	}
+	return Position{Line: line - 1, Character: utf16Len(text[:n])}
+}
+
+// column converts pos to a line and byte column, both counted from 1.
+func (d *document) column(pos Position) (int, int) {
+	if pos.Line >= len(d.lines) {
+		return pos.Line + 1, 1
+	}
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		units += utf16Len(string(r))
+	}
+	return pos.Line + 1, len(text) + 1
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// width returns the number of bytes tok spans in the source. The literals
+// of strings leave out their quotes.
+func width(tok token.Token) int {
+	n := len(tok.Literal)
+	if tok.Type == token.STRING || tok.Type == token.ISTRING {
+		n += 2
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+func (d *document) tokenRange(tok token.Token) Range {
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+width(tok)),
+	}
+}
+
+// tokenAt returns the index of the token at a line and column, or of the
+// token that ends there, as it does when the cursor follows a name.
+func (d *document) tokenAt(line, column int) (int, bool) {
+	found := -1
+	for i, tok := range d.tokens {
+		if tok.Line != line {
+			continue
+		}
+		if tok.Column <= column && column < tok.Column+width(tok) {
+			return i, true
+		}
+		if tok.Column+width(tok) == column {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// indexOf returns the index of tok in the document's tokens.
+func (d *document) indexOf(tok token.Token) (int, bool) {
+	for i, t := range d.tokens {
+		if t.Line == tok.Line && t.Column == tok.Column {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// reference returns what the name tok refers to.
+func (d *document) reference(tok token.Token) (lint.Reference, bool) {
+	for _, ref := range d.references {
+		if ref.Use.Line == tok.Line && ref.Use.Column == tok.Column {
+			return ref, true
+		}
+	}
+	return lint.Reference{}, false
+}
+
+// lookup returns the last declaration of name before line, for names that
+// can't be resolved by position because the text around them doesn't parse.
+func (d *document) lookup(name string, line int) (lint.Declaration, bool) {
+	var decl lint.Declaration
+	found := false
+	for _, ref := range d.references {
+		if ref.Decl.Name == name && ref.Decl.Token.Line <= line {
+			decl, found = ref.Decl, true
+		}
+	}
+	return decl, found
+}
+
+// declarations returns the names declared in the document, in order.
+func (d *document) declarations() []lint.Declaration {
+	decls := []lint.Declaration{}
+	for _, ref := range d.references {
+		if ref.Use == ref.Decl.Token {
+			decls = append(decls, ref.Decl)
+		}
+	}
+	return decls
+}
+
+// isTopLevel reports whether node is one of the document's statements.
+func (d *document) isTopLevel(node ast.Node) bool {
+	for _, stmt := range d.program.Statements {
+		if stmt == node {
+			return true
+		}
+	}
+	return false
+}
+
+// module analyzes the module importpath as found from the document.
+func (d *document) module(importpath string) (*document, bool) {
+	filename, src, _, err := parser.ResolveModule(d.dir, d.includePaths, importpath)
+	if err != nil {
+		return nil, false
+	}
+	return newDocument(pathURI(filename), string(src), d.includePaths), true
+}
+
+// diagnostics returns the document's parse errors or, when it parses, the
+// problems lint finds in it.
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, e := range d.parseErrors {
+		r := Range{Start: d.position(e.Line, e.Column), End: d.position(e.Line, e.Column)}
+		if i, ok := d.indexOf(token.Token{Line: e.Line, Column: e.Column}); ok {
+			r = d.tokenRange(d.tokens[i])
+		}
+		message := e.Message
+		if e.Hint != "" {
+			message += "\nhint: " + e.Hint
+		}
+		diagnostics = append(diagnostics, Diagnostic{Range: r, Severity: SeverityError, Source: "monkey", Message: message})
+	}
+	for _, p := range d.problems {
+		r := Range{Start: d.position(p.Line, p.Column), End: d.position(p.Line, p.Column)}
+		if i, ok := d.indexOf(token.Token{Line: p.Line, Column: p.Column}); ok {
+			r = d.tokenRange(d.tokens[i])
+		}
+		diagnostics = append(diagnostics, Diagnostic{Range: r, Severity: SeverityWarning, Source: "monkey lint", Message: p.Message})
+	}
+	return diagnostics
+}
@@ -0,0 +1,535 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/eval"
+	"monkey/lint"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// values stand in for the values of each type whose methods can be listed
+// without running the program.
+var values = map[eval.ObjectType]eval.Object{
+	eval.INTEGER_OBJ: &eval.Integer{},
+	eval.STRING_OBJ:  &eval.String{},
+	eval.ARRAY_OBJ:   &eval.Array{},
+	eval.HASH_OBJ:    &eval.Hash{},
+	eval.SET_OBJ:     &eval.Set{},
+}
+
+// constructors are the builtins that return a value of a known type.
+var constructors = map[string]eval.ObjectType{
+	"int":       eval.INTEGER_OBJ,
+	"len":       eval.INTEGER_OBJ,
+	"ord":       eval.INTEGER_OBJ,
+	"str":       eval.STRING_OBJ,
+	"chr":       eval.STRING_OBJ,
+	"array":     eval.ARRAY_OBJ,
+	"methods":   eval.ARRAY_OBJ,
+	"set":       eval.SET_OBJ,
+	"chan":      eval.CHANNEL_OBJ,
+	"mutex":     eval.MUTEX_OBJ,
+	"waitgroup": eval.WAITGROUP_OBJ,
+	"re":        eval.REGEX_OBJ,
+	"open":      eval.FILE_OBJ,
+	"spawn":     eval.TASK_OBJ,
+}
+
+// typeOf returns the type of the value e evaluates to, when that can be
+// told from its syntax alone.
+func typeOf(e ast.Expression) eval.ObjectType {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return eval.INTEGER_OBJ
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return eval.STRING_OBJ
+	case *ast.Boolean:
+		return eval.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return eval.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return eval.HASH_OBJ
+	case *ast.SetLiteral:
+		return eval.SET_OBJ
+	case *ast.FunctionLiteral:
+		return eval.FUNCTION_OBJ
+	case *ast.StructLiteral:
+		return eval.STRUCT_OBJ
+	case *ast.CallExpression:
+		if id, ok := e.Function.(*ast.Identifier); ok {
+			return constructors[id.Value]
+		}
+	}
+	return ""
+}
+
+func code(s string) string {
+	return "```monkey\n" + s + "\n```"
+}
+
+// signature returns fn without its body.
+func signature(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for _, p := range fn.Parameters {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// summary returns e shortened to fit on one line.
+func summary(e ast.Expression) string {
+	if fn, ok := e.(*ast.FunctionLiteral); ok {
+		return signature(fn)
+	}
+	s := strings.Join(strings.Fields(e.String()), " ")
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+// structSource returns a struct statement with its method bodies left out.
+func structSource(stmt *ast.StructStatement) string {
+	var out strings.Builder
+	out.WriteString("struct " + stmt.Name.Value)
+	if stmt.Embeds != nil {
+		out.WriteString("(" + stmt.Embeds.Value + ")")
+	}
+	out.WriteString(" {\n")
+	fields := []string{}
+	for _, f := range stmt.Fields {
+		if f.Default != nil {
+			fields = append(fields, f.Name.Value+" = "+summary(f.Default))
+		} else {
+			fields = append(fields, f.Name.Value)
+		}
+	}
+	if len(fields) > 0 {
+		out.WriteString("  " + strings.Join(fields, ", ") + "\n")
+	}
+	for _, m := range stmt.Methods {
+		out.WriteString("  fn " + m.Name.Value + strings.TrimPrefix(signature(m.Function), "fn") + "\n")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// builtinDoc describes a builtin function from its docstring, which starts
+// with how it's called. The functions of builtin modules are named with
+// their module.
+func builtinDoc(name string, b *eval.Builtin) string {
+	call := name + "()"
+	if strings.HasPrefix(b.Doc, name+"(") {
+		call = b.Doc[:strings.Index(b.Doc, ")")+1]
+	}
+	if b.Doc == "" {
+		return code(call)
+	}
+	return code(call) + "\n\n" + b.Doc
+}
+
+// describe returns what hover shows for a declared name.
+func (d *document) describe(decl lint.Declaration) string {
+	switch decl.Kind {
+	case "let":
+		if decl.Value == nil {
+			return code("let " + decl.Name)
+		}
+		text := code("let " + decl.Name + " = " + summary(decl.Value))
+		if t := typeOf(decl.Value); t != "" {
+			text += "\n\n" + string(t)
+		}
+		return text
+	case "parameter":
+		return code(decl.Name) + "\n\nparameter of `" + signature(decl.Node.(*ast.FunctionLiteral)) + "`"
+	case "struct":
+		return code(structSource(decl.Node.(*ast.StructStatement)))
+	case "field":
+		return code(decl.Name) + "\n\nfield of struct " + decl.Node.(*ast.StructStatement).Name.Value
+	case "include":
+		text := code("include " + decl.Name)
+		if filename, _, _, err := parser.ResolveModule(d.dir, d.includePaths, decl.Name); err == nil {
+			text += "\n\n" + filename
+		}
+		return text
+	case "import":
+		return code(decl.Node.String())
+	}
+	if m, ok := eval.LookupBuiltinModule(decl.Name); ok {
+		names := []string{}
+		for name := range m.Functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return code("builtin module "+decl.Name) + "\n\n" + strings.Join(names, ", ")
+	}
+	switch decl.Name {
+	case "args":
+		return code("args") + "\n\nthe command line arguments the program was run with, an array of strings"
+	case "self":
+		return code("self") + "\n\nthe struct a method added with addm was called on"
+	}
+	return ""
+}
+
+// modulePath returns the path of the module decl binds, if it binds one.
+func modulePath(decl lint.Declaration) (string, bool) {
+	switch decl.Kind {
+	case "include":
+		return decl.Name, true
+	case "import":
+		if stmt := decl.Node.(*ast.ImportStatement); len(stmt.Names) == 0 {
+			return stmt.Path, true
+		}
+	}
+	return "", false
+}
+
+// member returns the declaration of name in the module receiver refers to.
+func (d *document) member(receiver lint.Declaration, name string) (*document, lint.Declaration, bool) {
+	path, ok := modulePath(receiver)
+	if !ok {
+		return nil, lint.Declaration{}, false
+	}
+	m, ok := d.module(path)
+	if !ok {
+		return nil, lint.Declaration{}, false
+	}
+	for _, decl := range m.declarations() {
+		if decl.Name == name && m.isTopLevel(decl.Node) {
+			return m, decl, true
+		}
+	}
+	return nil, lint.Declaration{}, false
+}
+
+// describeMember returns what hover shows for name after a dot.
+func (d *document) describeMember(receiver token.Token, name string) string {
+	if receiver.Type != token.IDENT {
+		return ""
+	}
+	ref, ok := d.reference(receiver)
+	if !ok {
+		return ""
+	}
+	if m, decl, ok := d.member(ref.Decl, name); ok {
+		return m.describe(decl) + "\n\nin module " + ref.Decl.Name
+	}
+	if bm, ok := eval.LookupBuiltinModule(ref.Decl.Name); ok && ref.Decl.Kind == "predeclared" {
+		if b, ok := bm.Functions[name]; ok {
+			return builtinDoc(ref.Decl.Name+"."+name, b)
+		}
+	}
+	t := typeOf(ref.Decl.Value)
+	if t == "" || t == eval.STRUCT_OBJ {
+		return ""
+	}
+	text := code(string(t) + "." + name + "()")
+	if m, ok := eval.LookupMethod(t, name); ok && m.Doc != "" {
+		text += "\n\n" + m.Doc
+	}
+	return text
+}
+
+func (d *document) hover(pos Position) *Hover {
+	i, ok := d.tokenAt(d.column(pos))
+	if !ok || d.tokens[i].Type != token.IDENT {
+		return nil
+	}
+	tok := d.tokens[i]
+	var text string
+	if i >= 2 && d.tokens[i-1].Type == token.DOT {
+		text = d.describeMember(d.tokens[i-2], tok.Literal)
+	} else if ref, ok := d.reference(tok); ok {
+		text = d.describe(ref.Decl)
+	} else if b, ok := eval.LookupBuiltin(tok.Literal); ok {
+		text = builtinDoc(tok.Literal, b)
+	}
+	if text == "" {
+		return nil
+	}
+	r := d.tokenRange(tok)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// moduleLocation returns the start of the module file importpath, unless it
+// is part of the bundled standard library, which has no file to show.
+func (d *document) moduleLocation(importpath string) *Location {
+	filename, _, _, err := parser.ResolveModule(d.dir, d.includePaths, importpath)
+	if err != nil || strings.HasPrefix(filename, parser.StdlibDir+"/") {
+		return nil
+	}
+	return &Location{URI: pathURI(filename)}
+}
+
+func (d *document) definition(pos Position) *Location {
+	i, ok := d.tokenAt(d.column(pos))
+	if !ok {
+		return nil
+	}
+	tok := d.tokens[i]
+	if tok.Type == token.STRING && i >= 1 && (d.tokens[i-1].Type == token.IMPORT || d.tokens[i-1].Type == token.FROM) {
+		return d.moduleLocation(tok.Literal)
+	}
+	if tok.Type != token.IDENT {
+		return nil
+	}
+	if i >= 2 && d.tokens[i-1].Type == token.DOT {
+		receiver, ok := d.reference(d.tokens[i-2])
+		if !ok {
+			return nil
+		}
+		m, decl, ok := d.member(receiver.Decl, tok.Literal)
+		if !ok || strings.HasPrefix(m.uri, pathURI(parser.StdlibDir)+"/") {
+			return nil
+		}
+		return &Location{URI: m.uri, Range: m.tokenRange(decl.Token)}
+	}
+	ref, ok := d.reference(tok)
+	if !ok {
+		return nil
+	}
+	switch ref.Decl.Kind {
+	case "predeclared":
+		return nil
+	case "include":
+		return d.moduleLocation(ref.Decl.Name)
+	case "import":
+		return d.moduleLocation(ref.Decl.Node.(*ast.ImportStatement).Path)
+	}
+	return &Location{URI: d.uri, Range: d.tokenRange(ref.Decl.Token)}
+}
+
+// completionKind returns the kind of completion item for a declared name.
+func completionKind(decl lint.Declaration) int {
+	switch decl.Kind {
+	case "struct":
+		return CompletionStruct
+	case "field":
+		return CompletionField
+	case "include", "import":
+		return CompletionModule
+	}
+	if _, ok := decl.Value.(*ast.FunctionLiteral); ok {
+		return CompletionFunction
+	}
+	return CompletionVariable
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func (d *document) completion(pos Position) []CompletionItem {
+	line, column := d.column(pos)
+	items := []CompletionItem{}
+	if line > len(d.lines) {
+		return items
+	}
+	text := d.lines[line-1][:column-1]
+	start := len(text)
+	for start > 0 && isNameByte(text[start-1]) {
+		start--
+	}
+	prefix := text[start:]
+	if start > 0 && text[start-1] == '.' {
+		end := start - 1
+		begin := end
+		for begin > 0 && isNameByte(text[begin-1]) {
+			begin--
+		}
+		items = d.members(text[begin:end], line)
+	} else {
+		items = d.names()
+	}
+
+	seen := make(map[string]bool)
+	matches := []CompletionItem{}
+	for _, item := range items {
+		if strings.HasPrefix(item.Label, prefix) && !seen[item.Label] {
+			seen[item.Label] = true
+			matches = append(matches, item)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Label < matches[j].Label })
+	return matches
+}
+
+// names returns the names that can start an expression.
+func (d *document) names() []CompletionItem {
+	items := []CompletionItem{}
+	for _, decl := range d.declarations() {
+		item := CompletionItem{Label: decl.Name, Kind: completionKind(decl)}
+		if decl.Value != nil {
+			item.Detail = summary(decl.Value)
+		}
+		items = append(items, item)
+	}
+	items = append(items, CompletionItem{Label: "args", Kind: CompletionVariable})
+	for _, name := range eval.BuiltinNames() {
+		b, _ := eval.LookupBuiltin(name)
+		items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin", Documentation: b.Doc})
+	}
+	for _, name := range eval.BuiltinModuleNames() {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionModule, Detail: "builtin module"})
+	}
+	for _, word := range token.Keywords() {
+		items = append(items, CompletionItem{Label: word, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// members returns what can follow receiver and a dot: the names declared by
+// a module, the functions of a builtin module or the methods of a value. If
+// the type of the value isn't known, the methods of every type are offered.
+func (d *document) members(receiver string, line int) []CompletionItem {
+	items := []CompletionItem{}
+	decl, ok := d.lookup(receiver, line)
+	if !ok {
+		if _, builtin := eval.LookupBuiltinModule(receiver); builtin {
+			decl, ok = lint.Declaration{Name: receiver, Kind: "predeclared"}, true
+		}
+	}
+	path, isModule := modulePath(decl)
+	switch {
+	case ok && isModule:
+		m, ok := d.module(path)
+		if !ok {
+			return items
+		}
+		for _, decl := range m.declarations() {
+			if m.isTopLevel(decl.Node) {
+				items = append(items, CompletionItem{Label: decl.Name, Kind: completionKind(decl)})
+			}
+		}
+		return items
+	case ok && decl.Kind == "predeclared":
+		if bm, ok := eval.LookupBuiltinModule(decl.Name); ok {
+			for name, b := range bm.Functions {
+				items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Documentation: b.Doc})
+			}
+			return items
+		}
+	case ok:
+		if lit, ok := decl.Value.(*ast.StructLiteral); ok {
+			for _, key := range lit.Order {
+				items = append(items, CompletionItem{Label: key.String(), Kind: CompletionField})
+			}
+			return items
+		}
+		if _, ok := values[typeOf(decl.Value)]; ok {
+			return methods(typeOf(decl.Value))
+		}
+	}
+	for t := range values {
+		items = append(items, methods(t)...)
+	}
+	return items
+}
+
+// methods returns the methods of values of type t.
+func methods(t eval.ObjectType) []CompletionItem {
+	items := []CompletionItem{}
+	for _, name := range eval.MethodNames(values[t]) {
+		item := CompletionItem{Label: name, Kind: CompletionMethod, Detail: string(t)}
+		if m, ok := eval.LookupMethod(t, name); ok {
+			item.Documentation = m.Doc
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// extent returns the range of the statement that starts with tok: up to the
+// last token before the line ends or a semicolon, outside any brackets.
+func (d *document) extent(tok token.Token) Range {
+	i, ok := d.indexOf(tok)
+	if !ok {
+		return d.tokenRange(tok)
+	}
+	depth := 0
+	for ; i < len(d.tokens); i++ {
+		t := d.tokens[i]
+		switch t.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last := i+1 == len(d.tokens)
+		if depth <= 0 && (last || d.tokens[i+1].Type == token.SEMICOLON || d.tokens[i+1].Line > t.Line) {
+			break
+		}
+	}
+	if i == len(d.tokens) {
+		i--
+	}
+	return Range{Start: d.position(tok.Line, tok.Column), End: d.tokenRange(d.tokens[i]).End}
+}
+
+// symbols outlines the names the document declares at the top level.
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	let := func(stmt *ast.LetStatement, start token.Token) {
+		symbol := DocumentSymbol{
+			Name:           stmt.Name.Value,
+			Kind:           SymbolVariable,
+			Range:          d.extent(start),
+			SelectionRange: d.tokenRange(stmt.Name.Token),
+		}
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SymbolFunction
+			symbol.Detail = signature(fn)
+		}
+		symbols = append(symbols, symbol)
+	}
+	for _, stmt := range d.program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			let(stmt, stmt.Token)
+		case *ast.ExportStatement:
+			if stmt.Statement != nil {
+				let(stmt.Statement, stmt.Token)
+			}
+		case *ast.StructStatement:
+			symbol := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolStruct,
+				Range:          d.extent(stmt.Token),
+				SelectionRange: d.tokenRange(stmt.Name.Token),
+			}
+			for _, f := range stmt.Fields {
+				r := d.tokenRange(f.Name.Token)
+				symbol.Children = append(symbol.Children, DocumentSymbol{Name: f.Name.Value, Kind: SymbolField, Range: r, SelectionRange: r})
+			}
+			for _, m := range stmt.Methods {
+				r := d.tokenRange(m.Name.Token)
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name: m.Name.Value, Detail: signature(m.Function), Kind: SymbolMethod, Range: r, SelectionRange: r,
+				})
+			}
+			symbols = append(symbols, symbol)
+		case *ast.IncludeStatement:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           stmt.IncludePath.String(),
+				Kind:           SymbolModule,
+				Range:          d.extent(stmt.Token),
+				SelectionRange: d.extent(stmt.Token),
+			})
+		case *ast.ImportStatement:
+			if len(stmt.Names) == 0 {
+				symbols = append(symbols, DocumentSymbol{
+					Name:           stmt.Binding(),
+					Detail:         stmt.Path,
+					Kind:           SymbolModule,
+					Range:          d.extent(stmt.Token),
+					SelectionRange: d.extent(stmt.Token),
+				})
+			}
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,210 @@
+// Package lsp implements a Language Server Protocol server for monkey, so
+// that editors can show parse errors and lint problems as a file is edited,
+// describe builtins and names on hover, jump to where names are declared,
+// complete names and methods, and outline a file's declarations.
+//
+// The server reads JSON-RPC messages framed by Content-Length headers and
+// keeps the text of the open documents, which the client sends in full on
+// every change.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// Server answers the requests of one client.
+type Server struct {
+	// IncludePaths are searched for modules after a document's directory.
+	IncludePaths []string
+
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer returns a server reading requests from in and writing responses
+// to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: make(map[string]*document)}
+}
+
+// errNoShutdown is returned by Serve when the client exits without asking
+// the server to shut down first.
+var errNoShutdown = errors.New("exit without shutdown")
+
+// Serve handles messages until the client sends exit or closes its end of
+// the connection.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, codeParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errNoShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+// read returns the body of the next message.
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, fmt.Errorf("reading body: %v", err)
+	}
+	return body, nil
+}
+
+func (s *Server) write(v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(body)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}) error {
+	return s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) error {
+	return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle answers a request or acts on a notification. Unknown
+// notifications are ignored.
+func (s *Server) handle(req *request) error {
+	if s.shutdown && req.ID != nil {
+		return s.replyError(req.ID, codeInvalidRequest, "server is shut down")
+	}
+	switch req.Method {
+	case "initialize":
+		return s.reply(req.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// full text on every change
+				"textDocumentSync":       1,
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+			},
+			"serverInfo": map[string]string{"name": "monkey"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(req.ID, nil)
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil
+		}
+		return s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil
+		}
+		delete(s.docs, params.TextDocument.URI)
+		// clear the closed file's problems
+		return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+			URI: params.TextDocument.URI, Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/hover":
+		return s.atPosition(req, func(d *document, pos Position) interface{} {
+			return d.hover(pos)
+		})
+	case "textDocument/definition":
+		return s.atPosition(req, func(d *document, pos Position) interface{} {
+			return d.definition(pos)
+		})
+	case "textDocument/completion":
+		return s.atPosition(req, func(d *document, pos Position) interface{} {
+			return d.completion(pos)
+		})
+	case "textDocument/documentSymbol":
+		var params documentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.replyError(req.ID, codeInvalidParams, err.Error())
+		}
+		d, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.reply(req.ID, []DocumentSymbol{})
+		}
+		return s.reply(req.ID, d.symbols())
+	}
+	if req.ID != nil {
+		return s.replyError(req.ID, codeMethodNotFound, "method not supported: "+req.Method)
+	}
+	return nil
+}
+
+// open analyzes the text of the document uri and publishes its problems.
+func (s *Server) open(uri, text string) error {
+	d := newDocument(uri, text, s.IncludePaths)
+	s.docs[uri] = d
+	return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{
+		URI: uri, Diagnostics: d.diagnostics(),
+	})
+}
+
+// atPosition answers a request about a position in an open document with the
+// result of fn, or null for documents that aren't open.
+func (s *Server) atPosition(req *request, fn func(*document, Position) interface{}) error {
+	var params positionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+	d, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return s.reply(req.ID, nil)
+	}
+	return s.reply(req.ID, fn(d, params.Position))
+}
@@ -0,0 +1,291 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// frame returns messages as a client sends them.
+func frame(messages ...string) string {
+	var out bytes.Buffer
+	for _, m := range messages {
+		fmt.Fprintf(&out, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	return out.String()
+}
+
+// unframe returns the bodies of the messages a server wrote.
+func unframe(t *testing.T, out []byte) []map[string]interface{} {
+	messages := []map[string]interface{}{}
+	s := NewServer(bytes.NewReader(out), nil)
+	for {
+		body, err := s.read()
+		if err != nil {
+			break
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("bad message %s: %v", body, err)
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func TestServe(t *testing.T) {
+	input := frame(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///tmp/a.my","version":1,"text":"let x = 1\nputs(y)"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///tmp/a.my","version":2},"contentChanges":[{"text":"let x = (1"}]}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///tmp/b.my"},"position":{"line":0,"character":0}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	var out bytes.Buffer
+	if err := NewServer(strings.NewReader(input), &out).Serve(); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	messages := unframe(t, out.Bytes())
+	expected := []string{
+		`{"id":1,"jsonrpc":"2.0","result":{"capabilities":{"completionProvider":{"triggerCharacters":["."]},"definitionProvider":true,"documentSymbolProvider":true,"hoverProvider":true,"textDocumentSync":1},"serverInfo":{"name":"monkey"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[{"message":"undefined: y","range":{"end":{"character":6,"line":1},"start":{"character":5,"line":1}},"severity":2,"source":"monkey lint"}],"uri":"file:///tmp/a.my"}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[{"message":"expected next token to be ), got EOF instead\nhint: the input ended in the middle of a statement; is a closing bracket missing?","range":{"end":{"character":10,"line":0},"start":{"character":10,"line":0}},"severity":1,"source":"monkey"}],"uri":"file:///tmp/a.my"}}`,
+		`{"id":2,"jsonrpc":"2.0","result":null}`,
+		`{"error":{"code":-32601,"message":"method not supported: workspace/symbol"},"id":3,"jsonrpc":"2.0"}`,
+		`{"id":4,"jsonrpc":"2.0","result":null}`,
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %d: %v", len(expected), len(messages), messages)
+	}
+	for i, m := range messages {
+		got, _ := json.Marshal(m)
+		if string(got) != expected[i] {
+			t.Errorf("message %d: expected\n%s\ngot\n%s", i, expected[i], got)
+		}
+	}
+}
+
+func TestHalfTypedStrings(t *testing.T) {
+	input := frame(
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///tmp/a.my","version":1,"text":"let x = 1\nputs('v is {x"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///tmp/a.my","version":2},"contentChanges":[{"text":"let x = 1\nputs('{}"}]}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///tmp/a.my"},"position":{"line":0,"character":4}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- NewServer(strings.NewReader(input), &out).Serve() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server stopped answering")
+	}
+	messages := unframe(t, out.Bytes())
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %v", len(messages), messages)
+	}
+	for _, m := range messages[:2] {
+		params := m["params"].(map[string]interface{})
+		if diagnostics := params["diagnostics"].([]interface{}); len(diagnostics) != 1 {
+			t.Errorf("expected one diagnostic, got %v", diagnostics)
+		}
+	}
+	if messages[2]["result"] == nil {
+		t.Errorf("expected a hover for x, got %v", messages[2])
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	input := frame(`{"jsonrpc":"2.0","method":"exit"}`)
+	if err := NewServer(strings.NewReader(input), ioutil.Discard).Serve(); err != errNoShutdown {
+		t.Errorf("expected %v, got %v", errNoShutdown, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	s := NewServer(bufio.NewReader(strings.NewReader("Content-Type: x\r\nContent-Length: 2\r\n\r\n{}")), nil)
+	body, err := s.read()
+	if err != nil || string(body) != "{}" {
+		t.Errorf("expected {}, got %q, %v", body, err)
+	}
+	s = NewServer(strings.NewReader("Content-Length: x\r\n\r\n{}"), nil)
+	if _, err := s.read(); err == nil {
+		t.Errorf("expected an error for a bad Content-Length")
+	}
+}
+
+// at returns the position of the first occurrence of marker in the
+// document text, offset by n characters.
+func at(text, marker string, n int) Position {
+	i := strings.Index(text, marker)
+	if i < 0 {
+		panic("no " + marker + " in " + text)
+	}
+	line := strings.Count(text[:i], "\n")
+	return Position{Line: line, Character: i - strings.LastIndex(text[:i], "\n") - 1 + n}
+}
+
+const source = `let add = fn(a, b) { a + b }
+let s = "héllo"
+struct Point {
+  x, y = 0
+  fn norm(self) { self.x * self.x }
+}
+let h = {"k" -> 1}
+puts(add(1, 2), s.upper(), json.stringify(h), len(h.keys()))`
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		marker   string
+		offset   int
+		expected string
+	}{
+		{"add(1", 1, "```monkey\nlet add = fn(a, b)\n```\n\nFUNCTION"},
+		{"a + b", 0, "```monkey\na\n```\n\nparameter of `fn(a, b)`"},
+		{"s.upper", 0, "```monkey\nlet s = \"héllo\"\n```\n\nSTRING"},
+		{"upper", 2, "```monkey\nSTRING.upper()\n```"},
+		{"len(", 0, "```monkey\nlen(value)\n```\n\nlen(value) returns the length of a string, array, set or a struct with a __len__ method."},
+		{"Point", 0, "```monkey\nstruct Point {\n  x, y = 0\n  fn norm(self)\n}\n```"},
+		{"json", 0, "```monkey\nbuiltin module json\n```\n\nparse, stringify"},
+		{"stringify", 0, "```monkey\njson.stringify(value[, indent])\n```\n\njson.stringify(value[, indent]) returns value encoded as JSON, " +
+			"indented by indent spaces or by the string indent when it's given."},
+		{"keys", 0, "```monkey\nHASH.keys()\n```"},
+		{"fn(a", 0, ""},
+		{"\"héllo", 1, ""},
+	}
+	d := newDocument("file:///tmp/a.my", source, nil)
+	for _, tt := range tests {
+		h := d.hover(at(source, tt.marker, tt.offset))
+		got := ""
+		if h != nil {
+			got = h.Contents.Value
+		}
+		if got != tt.expected {
+			t.Errorf("hover over %q: expected %q, got %q", tt.marker, tt.expected, got)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	module := "let greeting = \"hi\"\nlet greet = fn(name) { greeting + name }\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "greet.my"), []byte(module), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text := "include greet\nimport \"greet\" as g\nlet f = fn(x) { x }\nf(greet.greet(\"a\"), g.greeting, len(args))"
+	uri := pathURI(filepath.Join(dir, "main.my"))
+	moduleURI := pathURI(filepath.Join(dir, "greet.my"))
+	tests := []struct {
+		marker   string
+		offset   int
+		expected *Location
+	}{
+		{"f(greet", 0, &Location{URI: uri, Range: Range{Position{2, 4}, Position{2, 5}}}},
+		{"x }", 0, &Location{URI: uri, Range: Range{Position{2, 11}, Position{2, 12}}}},
+		{"greet.", 0, &Location{URI: moduleURI}},
+		{"greet(", 0, &Location{URI: moduleURI, Range: Range{Position{1, 4}, Position{1, 9}}}},
+		{"greeting,", 0, &Location{URI: moduleURI, Range: Range{Position{0, 4}, Position{0, 12}}}},
+		{"\"greet\"", 0, &Location{URI: moduleURI}},
+		{"include", 0, nil},
+		{"args", 0, nil},
+		{"len", 0, nil},
+	}
+	d := newDocument(uri, text, nil)
+	for _, tt := range tests {
+		got := d.definition(at(text, tt.marker, tt.offset))
+		if (got == nil) != (tt.expected == nil) || got != nil && *got != *tt.expected {
+			t.Errorf("definition of %q: expected %+v, got %+v", tt.marker, tt.expected, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+		excluded []string
+	}{
+		{"let total = 1\nlet tally = fn() { 2 }\nt", []string{"tally", "total", "true"}, []string{"len"}},
+		{"let s = \"a\"\ns.up", []string{"upper"}, []string{"keys"}},
+		{"let s = \"a\"\ns.", []string{"starts_with", "pad_left", "is_digit", "find_all"}, []string{"startswith"}},
+		{"let h = {}\nh.", []string{"keys", "values"}, []string{"upper"}},
+		{"let p = struct(a -> 1, b -> 2)\np.", []string{"a", "b"}, []string{"upper"}},
+		{"json.", []string{"parse", "stringify"}, nil},
+		{"let f = fn(x) { x.", []string{"keys", "upper"}, nil},
+		{"le", []string{"len", "let"}, []string{"puts"}},
+	}
+	for _, tt := range tests {
+		d := newDocument("file:///tmp/a.my", tt.text, nil)
+		lines := strings.Split(tt.text, "\n")
+		pos := Position{Line: len(lines) - 1, Character: len(lines[len(lines)-1])}
+		labels := make(map[string]bool)
+		for _, item := range d.completion(pos) {
+			labels[item.Label] = true
+		}
+		for _, label := range tt.expected {
+			if !labels[label] {
+				t.Errorf("%q: %s not offered", tt.text, label)
+			}
+		}
+		for _, label := range tt.excluded {
+			if labels[label] {
+				t.Errorf("%q: %s offered", tt.text, label)
+			}
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	d := newDocument("file:///tmp/a.my", source, nil)
+	got := []string{}
+	var describe func(prefix string, symbols []DocumentSymbol)
+	describe = func(prefix string, symbols []DocumentSymbol) {
+		for _, s := range symbols {
+			got = append(got, fmt.Sprintf("%s%s %d %d:%d-%d:%d", prefix, s.Name, s.Kind,
+				s.Range.Start.Line, s.Range.Start.Character, s.Range.End.Line, s.Range.End.Character))
+			describe(prefix+"  ", s.Children)
+		}
+	}
+	describe("", d.symbols())
+	expected := []string{
+		"add 12 0:0-0:28",
+		"s 13 1:0-1:15",
+		"Point 23 2:0-5:1",
+		"  x 8 3:2-3:3",
+		"  y 8 3:5-3:6",
+		"  norm 6 4:5-4:9",
+		"h 13 6:0-6:18",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := newDocument("file:///tmp/a.my", "let s = \"😀é\"; s", nil)
+	// the emoji is two UTF-16 code units and four bytes, é one and two
+	if got := d.position(1, 19); got != (Position{0, 15}) {
+		t.Errorf("position: expected {0 15}, got %v", got)
+	}
+	if line, column := d.column(Position{0, 15}); line != 1 || column != 19 {
+		t.Errorf("column: expected 1:19, got %d:%d", line, column)
+	}
+}
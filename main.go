@@ -1,47 +1,123 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"monkey/eval"
-	"monkey/lexer"
-	"monkey/parser"
 	"monkey/repl"
 	"os"
+	"strings"
 )
 
-func runProgram(filename string) {
-	wd, err := os.Getwd()
+// exit statuses other than those passed to exit()
+const (
+	exitError      = 1
+	exitParseError = 2
+)
+
+// pathList collects repeated -I flags
+type pathList []string
+
+func (l *pathList) String() string { return strings.Join(*l, string(os.PathListSeparator)) }
+func (l *pathList) Set(dir string) error {
+	*l = append(*l, dir)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: monkey [flags] [file | -] [args...]
+       monkey [flags] -e code [args...]
+%s
+Without a file the REPL starts, unless standard input isn't a terminal, in
+which case the program is read from it, as it is for a file named -. The
+remaining arguments are passed to the program in the array args. Tools
+that read files read standard input when none are given.
+
+`, subcommandUsage())
+	flag.PrintDefaults()
+}
+
+// stdinIsTerminal reports whether standard input is interactive.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runProgram runs the program in src, or in the file filename when src is
+// nil, and returns the exit status.
+func runProgram(filename string, src []byte, args []string, includePaths []string) int {
+	in, err := eval.NewInterpreter(eval.Options{IncludePaths: includePaths})
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "monkey:", err)
+		return exitError
+	}
+	if args == nil {
+		args = []string{}
+	}
+	if err := in.Set("args", args); err != nil {
+		fmt.Fprintln(os.Stderr, "monkey:", err)
+		return exitError
+	}
+	var e eval.Object
+	if src != nil {
+		e, err = in.Eval(string(src))
+	} else {
+		e, err = in.EvalFile(filename)
+	}
+	switch err := err.(type) {
+	case nil:
+	case *eval.ExitError:
+		return err.Code
+	case *eval.ParseError:
+		if src == nil {
+			src, _ = ioutil.ReadFile(filename)
+		}
+		printDiagnostics(filename, src, err.Diagnostics)
+		return exitParseError
+	case *eval.Error:
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		return exitError
+	default:
+		fmt.Fprintln(os.Stderr, "monkey:", err)
+		return exitError
 	}
-	f, err := ioutil.ReadFile(wd + "/" + filename)
-	if err != nil {
-		fmt.Println("monkey: ", err.Error())
-		os.Exit(1)
-	}
-	l := lexer.New(string(f))
-	p := parser.New(l, wd)
-	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		fmt.Println(p.Errors()[0])
-		os.Exit(1)
-	}
-	scope := eval.NewScope(nil)
-	e := eval.Eval(program, scope)
 	if e.Inspect() != "null" {
 		fmt.Println(e.Inspect())
 	}
+	return 0
 }
 
 func main() {
-	args := os.Args[1:]
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+	var includePaths pathList
+	flag.Var(&includePaths, "I", "add `dir` to the module search path; may be repeated")
+	code := flag.String("e", "", "run `code` instead of a file")
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
 
-	if len(args) == 0 {
-		fmt.Println("Monkey programming language REPL\n")
-		repl.Start(os.Stdout)
-	} else {
-		runProgram(args[0])
+	switch {
+	case *code != "":
+		os.Exit(runProgram("-e", []byte(*code), args, includePaths))
+	case len(args) > 0 && args[0] != "-":
+		os.Exit(runProgram(args[0], nil, args[1:], includePaths))
+	case len(args) > 0 || !stdinIsTerminal():
+		if len(args) > 0 {
+			args = args[1:]
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "monkey:", err)
+			os.Exit(exitError)
+		}
+		os.Exit(runProgram("<stdin>", src, args, includePaths))
 	}
+	fmt.Println("Monkey programming language REPL")
+	fmt.Println()
+	os.Exit(repl.Start(os.Stdout, includePaths))
 }
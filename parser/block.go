@@ -10,7 +10,7 @@ func (p *Parser) parseBlockStatement() ast.Expression {
 	expression.Statements = []ast.Statement{}
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementOrSkip()
 		if stmt != nil {
 			expression.Statements = append(expression.Statements, stmt)
 		}
@@ -46,7 +46,7 @@ func (p *Parser) parseMethodCallExpression(obj ast.Expression) ast.Expression {
 	p.nextToken()
 	name := p.parseIdentifier()
 	if !p.peekTokenIs(token.LPAREN) {
-		methodCall.Call = p.parseExpression(LOWEST)
+		methodCall.Call = name
 	} else {
 		p.nextToken()
 		methodCall.Call = p.parseCallExpressions(name)
@@ -42,19 +42,42 @@ func (p *Parser) parseHashExpression() ast.Expression {
 		p.nextToken()
 		return hash
 	}
-	for !p.curTokenIs(token.RBRACE) {
-		p.nextToken()
-		key := p.parseExpression(LOWEST)
+	p.nextToken()
+	key := p.parseExpression(LOWEST)
+	// a brace literal whose first member isn't followed by '->' is a set
+	if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE) {
+		return p.parseSetExpression(hash.Token, key)
+	}
+	for {
 		if !p.expectPeek(token.ARROW) {
 			return nil
 		}
 		p.nextToken()
 		hash.Pairs[key] = p.parseExpression(LOWEST)
+		hash.Order = append(hash.Order, key)
 		p.nextToken()
+		if p.curTokenIs(token.RBRACE) {
+			break
+		}
+		p.nextToken()
+		key = p.parseExpression(LOWEST)
 	}
 	return hash
 }
 
+func (p *Parser) parseSetExpression(tok token.Token, first ast.Expression) ast.Expression {
+	set := &ast.SetLiteral{Token: tok, Members: []ast.Expression{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		set.Members = append(set.Members, p.parseExpression(LOWEST))
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return set
+}
+
 func (p *Parser) parseStructExpression() ast.Expression {
 	s := &ast.StructLiteral{Token: p.curToken}
 	s.Pairs = make(map[ast.Expression]ast.Expression)
@@ -71,6 +94,7 @@ func (p *Parser) parseStructExpression() ast.Expression {
 		}
 		p.nextToken()
 		s.Pairs[key] = p.parseExpression(LOWEST)
+		s.Order = append(s.Order, key)
 		p.nextToken()
 	}
 	return s
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"strings"
+)
+
+// Diagnostic describes an error found while parsing.
+type Diagnostic struct {
+	// Line and Column locate the token the error was found at
+	Line    int
+	Column  int
+	Message string
+	// Expected is the token type that was wanted, if the error is a
+	// missing token, and Found the token that was there instead.
+	Expected token.TokenType
+	Found    token.Token
+	// Hint suggests a fix, when there is a likely one
+	Hint string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// Format returns the diagnostic as it's shown to users: the position in the
+// file name, the message, the line of src it's on with a caret under the
+// token and the hint.
+func (d *Diagnostic) Format(name, src string) string {
+	var out bytes.Buffer
+	if name != "" {
+		out.WriteString(name + ":")
+	}
+	out.WriteString(d.String() + "\n")
+	lines := strings.Split(src, "\n")
+	if d.Line >= 1 && d.Line <= len(lines) {
+		line := strings.TrimRight(lines[d.Line-1], "\r")
+		out.WriteString("    " + strings.Replace(line, "\t", " ", -1) + "\n")
+		if d.Column >= 1 && d.Column <= len(line)+1 {
+			out.WriteString("    " + strings.Repeat(" ", d.Column-1) + "^\n")
+		}
+	}
+	if d.Hint != "" {
+		out.WriteString("    hint: " + d.Hint + "\n")
+	}
+	return out.String()
+}
+
+// hint returns a suggestion for an error found at tok.
+func hint(expected token.TokenType, tok token.Token) string {
+	switch {
+	case tok.Type == token.EOF:
+		return "the input ended in the middle of a statement; is a closing bracket missing?"
+	case tok.Type == token.ILLEGAL && (strings.HasPrefix(tok.Literal, `"`) || strings.HasPrefix(tok.Literal, "'")):
+		return "the string is never closed"
+	case tok.Type == token.ILLEGAL:
+		return fmt.Sprintf("%q isn't part of the language", tok.Literal)
+	case expected == token.IDENT && token.LookupIdent(tok.Literal) != token.IDENT:
+		return fmt.Sprintf("%s is a keyword and can't be used as a name", tok.Literal)
+	case expected == token.ASSIGN && tok.Type == token.EQ:
+		return "use = to bind a name; == compares"
+	case expected == token.ARROW && tok.Type == token.COLON:
+		return "hash keys and values are separated by ->"
+	}
+	return ""
+}
+
+// errorAt records an error found at tok.
+func (p *Parser) errorAt(tok token.Token, msg string) {
+	p.report(&Diagnostic{Line: tok.Line, Column: tok.Column, Message: msg, Found: tok, Hint: hint("", tok)})
+}
+
+// report records d, unless the statement being parsed already has an error:
+// errors after the first are usually caused by it.
+func (p *Parser) report(d *Diagnostic) {
+	if p.failed {
+		return
+	}
+	p.failed = true
+	for _, seen := range p.diagnostics {
+		if *seen == *d {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// Diagnostics returns the errors found by ParseProgram, at most one for each
+// statement, in the order they were found.
+func (p *Parser) Diagnostics() []*Diagnostic {
+	return p.diagnostics
+}
+
+// Errors returns the messages of the errors found by ParseProgram, with
+// their positions.
+func (p *Parser) Errors() []string {
+	errors := make([]string, len(p.diagnostics))
+	for i, d := range p.diagnostics {
+		errors[i] = d.String()
+	}
+	return errors
+}
+
+// statementStarts are the tokens only a statement can start with
+var statementStarts = map[token.TokenType]bool{
+	token.LET:     true,
+	token.RETURN:  true,
+	token.INCLUDE: true,
+	token.IMPORT:  true,
+	token.FROM:    true,
+	token.EXPORT:  true,
+}
+
+// parseStatementOrSkip parses a statement. A statement with an error is
+// skipped, see synchronize, and nil returned, so that the error doesn't
+// cause more in the statements after it.
+func (p *Parser) parseStatementOrSkip() ast.Statement {
+	depth := len(p.brackets)
+	if opens(p.curToken.Type) {
+		depth--
+	}
+	p.failed = false
+	stmt := p.parseStatement()
+	if !p.failed {
+		return stmt
+	}
+	p.synchronize(depth)
+	p.failed = false
+	return nil
+}
+
+// synchronize skips tokens to the end of a statement that failed to parse,
+// which started with depth brackets open. The statement ends at a semicolon,
+// before a token that only starts statements, or at the end of a line,
+// whichever comes first once the brackets opened since its start are closed.
+// Parentheses and square brackets still open at the end of a line or before
+// a statement keyword are given up on. The current token is left at the statement's last token,
+// as it is after a statement that parsed.
+func (p *Parser) synchronize(depth int) {
+	for len(p.brackets) >= depth && !p.curTokenIs(token.EOF) {
+		if len(p.brackets) == depth {
+			if p.curTokenIs(token.SEMICOLON) || statementStarts[p.peekToken.Type] ||
+				p.peekTokenIs(token.RBRACE) || p.peekToken.Line > p.curToken.Line {
+				return
+			}
+		} else if p.brackets[len(p.brackets)-1] != token.LBRACE &&
+			(statementStarts[p.peekToken.Type] || p.peekToken.Line > p.curToken.Line) {
+			p.brackets = p.brackets[:depth]
+			return
+		}
+		if p.peekTokenIs(token.EOF) {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// track updates the open brackets for a new current token.
+func (p *Parser) track() {
+	switch t := p.curToken.Type; {
+	case opens(t):
+		p.brackets = append(p.brackets, t)
+	case t == token.RPAREN || t == token.RBRACE || t == token.RBRACKET:
+		if len(p.brackets) > 0 {
+			p.brackets = p.brackets[:len(p.brackets)-1]
+		}
+	}
+}
+
+func opens(t token.TokenType) bool {
+	return t == token.LPAREN || t == token.LBRACE || t == token.LBRACKET
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken, Dir: p.path, IncludePaths: p.includePaths}
+	if !p.parseImportPath(stmt) {
+		return nil
+	}
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseFromImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken, Dir: p.path, IncludePaths: p.includePaths}
+	if !p.parseImportPath(stmt) {
+		return nil
+	}
+	if !p.expectPeek(token.IMPORT) {
+		return nil
+	}
+	stmt.Names = p.parseIdentifierList()
+	if stmt.Names == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseImportPath accepts the module path as either a string, for paths
+// with separators, or a bare identifier.
+func (p *Parser) parseImportPath(stmt *ast.ImportStatement) bool {
+	if !p.peekTokenIs(token.STRING) && !p.peekTokenIs(token.IDENT) {
+		p.peekError(token.STRING)
+		return false
+	}
+	p.nextToken()
+	stmt.Path = p.curToken.Literal
+	return true
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if p.peekTokenIs(token.LET) {
+		p.nextToken()
+		stmt.Statement = p.parseLetStatement()
+		return stmt
+	}
+	stmt.Names = p.parseIdentifierList()
+	if stmt.Names == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseIdentifierList parses one or more comma separated identifiers
+// following the current token.
+func (p *Parser) parseIdentifierList() []*ast.Identifier {
+	names := []*ast.Identifier{}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		names = append(names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	return names
+}
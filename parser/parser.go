@@ -15,6 +15,8 @@ const (
 	AND
 	EQUALS
 	LESSGREATER
+	UNION
+	INTERSECTION
 	SLICE
 	SUM
 	PRODUCT
@@ -31,6 +33,8 @@ var precedences = map[token.TokenType]int{
 	token.NEQ:      EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.PIPE:     UNION,
+	token.AMP:      INTERSECTION,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.MOD:      PRODUCT,
@@ -43,13 +47,24 @@ var precedences = map[token.TokenType]int{
 }
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
-	path   string
+	l           *lexer.Lexer
+	diagnostics []*Diagnostic
+	path        string
+	// includePaths are searched, in order, for modules that aren't found
+	// relative to path. See ResolveModule.
+	includePaths []string
 
 	curToken  token.Token
 	peekToken token.Token
 
+	// brackets are the brackets open up to and including curToken
+	brackets []token.TokenType
+	// failed is set once the statement being parsed has an error
+	failed bool
+	// tokens are the tokens parsed so far, for tools that need the
+	// positions the syntax tree leaves out
+	tokens []token.Token
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -69,9 +84,8 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func New(l *lexer.Lexer, wd string) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
-		path:   wd,
+		l:    l,
+		path: wd,
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -85,6 +99,7 @@ func New(l *lexer.Lexer, wd string) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.DO, p.parseDoLoopExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteralExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayExpression)
 	p.registerPrefix(token.LBRACE, p.parseHashExpression)
@@ -109,6 +124,8 @@ func New(l *lexer.Lexer, wd string) *Parser {
 	p.registerInfix(token.DOT, p.parseMethodCallExpression)
 	p.registerInfix(token.COLON, p.parseSliceExpression)
 	p.registerInfix(token.MOD, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.AMP, p.parseInfixExpression)
 	p.nextToken()
 	p.nextToken()
 
@@ -118,19 +135,11 @@ func New(l *lexer.Lexer, wd string) *Parser {
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
-	program.Includes = make(map[string]*ast.IncludeStatement)
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementOrSkip()
 		if stmt != nil {
-			if include, ok := stmt.(*ast.IncludeStatement); ok {
-				_, ok := program.Includes[include.IncludePath.String()]
-				if !ok {
-					program.Includes[include.IncludePath.String()] = include
-				}
-			} else {
-				program.Statements = append(program.Statements, stmt)
-			}
+			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
@@ -145,6 +154,17 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseReturnStatement()
 	case token.INCLUDE:
 		return p.parseIncludeStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.FROM:
+		return p.parseFromImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.STRUCT:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseStructStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -212,7 +232,11 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse functions for '%s' found", t)
-	p.errors = append(p.errors, msg)
+	d := &Diagnostic{Line: p.curToken.Line, Column: p.curToken.Column, Message: msg, Found: p.curToken, Hint: hint("", p.curToken)}
+	if d.Hint == "" {
+		d.Hint = fmt.Sprintf("an expression was expected, not %q", p.curToken.Literal)
+	}
+	p.report(d)
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -223,23 +247,37 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns how tightly the infix operator t binds, from LOWEST
+// for tokens that aren't operators to INDEX.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
+
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.track()
+	if p.curToken.Line > 0 && p.curToken.Type != token.EOF {
+		p.tokens = append(p.tokens, p.curToken)
+	}
+}
+
+// Tokens returns the tokens parsed so far, in order. The text of
+// interpolated strings and their braces are left out, but the tokens of the
+// expressions in them are included.
+func (p *Parser) Tokens() []token.Token {
+	return p.tokens
 }
 
 func (p *Parser) nextInterpToken() {
@@ -258,9 +296,12 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
-}
-
-func (p *Parser) Errors() []string {
-	return p.errors
+	p.report(&Diagnostic{
+		Line:     p.peekToken.Line,
+		Column:   p.peekToken.Column,
+		Message:  msg,
+		Expected: t,
+		Found:    p.peekToken,
+		Hint:     hint(t, p.peekToken),
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
 	"os"
 	"strings"
 	"testing"
@@ -81,7 +82,7 @@ func TestParsingHashLiteralExpressions(t *testing.T) {
 	}
 	for key, value := range hash.Pairs {
 		if literal, ok := key.(*ast.StringLiteral); ok {
-			expectedValue := expected[literal.String()]
+			expectedValue := expected[literal.Value]
 			testIntegerLiteral(t, value, expectedValue)
 		} else {
 			t.Fatalf("key not *ast.StringLiteral. got=%T", key)
@@ -90,6 +91,28 @@ func TestParsingHashLiteralExpressions(t *testing.T) {
 
 }
 
+func TestParsingSetLiteralExpressions(t *testing.T) {
+	input := `{1, 2 + 3, "three"}`
+	l := lexer.New(input)
+	p := New(l, path)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	set, ok := stmt.Expression.(*ast.SetLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.SetLiteral. got=%T", stmt.Expression)
+	}
+	if len(set.Members) != 3 {
+		t.Fatalf("wrong number of set members. expected=3, got=%d", len(set.Members))
+	}
+	testIntegerLiteral(t, set.Members[0], 1)
+	testInfixExpression(t, set.Members[1], 2, "+", 3)
+	if set.String() != `{1, (2 + 3), "three"}` {
+		t.Errorf("set.String() wrong. got=%q", set.String())
+	}
+}
+
 func TestParsingMethodExpressions(t *testing.T) {
 	input := "array.len(1, 2)"
 	l := lexer.New(input)
@@ -259,6 +282,8 @@ func TestInterpolatedString(t *testing.T) {
 		{"'aa{[1,2,3]}{x()}{x+1}aa'", "aa{0}{1}{2}aa", 3},
 		{"'aa{[1,2,3]}b{x()}c{x+1}aa'", "aa{0}b{1}c{2}aa", 3},
 		{"'aa{x+1}abc'", "aa{0}abc", 1},
+		{"'{}'", "{}", 0},
+		{"'a {} {x}'", "a {} {0}", 1},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -318,35 +343,156 @@ func TestIncludeStatements(t *testing.T) {
 	tests := []struct {
 		input         string
 		expectedValue string
-		ismodule      bool
 	}{
-		{"include test_files", "test_files", true},
+		{"include test_files", "test_files"},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l, path)
+		p.SetIncludePaths([]string{"lib"})
 
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
 
-		if len(program.Includes) != 1 {
-			t.Fatalf("program.Includes does not contain 1 statements. got=%d", len(program.Includes))
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
 		}
-		for _, v := range program.Includes {
-			if !testLiteralExpression(t, v.IncludePath, tt.expectedValue) {
-				return
-			}
-			if v.IsModule != tt.ismodule {
-				t.Fatalf("Included value not %v. got=%v", v.IsModule, tt.ismodule)
-			}
-			if len(v.Program.Includes) != 3 {
-				t.Fatalf("Included Program had wrong number of modules. expected=3, got=%d", len(v.Program.Includes))
-			}
+		stmt, ok := program.Statements[0].(*ast.IncludeStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.IncludeStatement. got=%T", program.Statements[0])
+		}
+		if !testLiteralExpression(t, stmt.IncludePath, tt.expectedValue) {
+			return
+		}
+		if stmt.Dir != path {
+			t.Errorf("stmt.Dir not %q. got=%q", path, stmt.Dir)
+		}
+		if len(stmt.IncludePaths) != 1 || stmt.IncludePaths[0] != "lib" {
+			t.Errorf("stmt.IncludePaths not [lib]. got=%v", stmt.IncludePaths)
 		}
 	}
 }
 
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input   string
+		path    string
+		binding string
+		names   []string
+	}{
+		{`import "test_files/imports/geometry" as geo`, "test_files/imports/geometry", "geo", nil},
+		{`import "test_files/imports/plain"`, "test_files/imports/plain", "plain", nil},
+		{`import test_files`, "test_files", "test_files", nil},
+		{`from "test_files/imports/geometry" import area, unit`, "test_files/imports/geometry", "geometry", []string{"area", "unit"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l, path)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path != tt.path {
+			t.Errorf("stmt.Path not %q. got=%q", tt.path, stmt.Path)
+		}
+		if stmt.Binding() != tt.binding {
+			t.Errorf("stmt.Binding() not %q. got=%q", tt.binding, stmt.Binding())
+		}
+		if len(stmt.Names) != len(tt.names) {
+			t.Fatalf("wrong number of imported names. expected=%d, got=%d", len(tt.names), len(stmt.Names))
+		}
+		for i, name := range tt.names {
+			testIdentifier(t, stmt.Names[i], name)
+		}
+		if stmt.Dir != path {
+			t.Errorf("stmt.Dir not %q. got=%q", path, stmt.Dir)
+		}
+	}
+}
+
+func TestExportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`export let x = 5;`, []string{"x"}},
+		{`export a, b`, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l, path)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExportStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ExportStatement. got=%T", program.Statements[0])
+		}
+		exported := stmt.Exported()
+		if strings.Join(exported, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("wrong exported names. expected=%v, got=%v", tt.expected, exported)
+		}
+	}
+}
+
+func TestResolveModule(t *testing.T) {
+	libdir := path + "/test_files/libdir"
+	tests := []struct {
+		dir          string
+		includePaths []string
+		monkeypath   string
+		importpath   string
+		filename     string
+		module       bool
+	}{
+		{path, nil, "", "test_files/eval", path + "/test_files/eval.my", false},
+		{path, nil, "", "test_files", path + "/test_files/module.my", true},
+		{path, []string{libdir}, "", "helpers", libdir + "/helpers.my", false},
+		{path, nil, libdir, "helpers", libdir + "/helpers.my", false},
+		{path, nil, "", "math", StdlibDir + "/math.my", false},
+		{"/nonexistent", nil, "", path + "/test_files/test", path + "/test_files/test.my", false},
+	}
+
+	for _, tt := range tests {
+		os.Setenv("MONKEYPATH", tt.monkeypath)
+		filename, src, module, err := ResolveModule(tt.dir, tt.includePaths, tt.importpath)
+		os.Unsetenv("MONKEYPATH")
+		if err != nil {
+			t.Fatalf("could not resolve %s: %s", tt.importpath, err)
+		}
+		if filename != tt.filename {
+			t.Errorf("wrong file for %s. expected=%q, got=%q", tt.importpath, tt.filename, filename)
+		}
+		if module != tt.module {
+			t.Errorf("wrong module flag for %s. expected=%v, got=%v", tt.importpath, tt.module, module)
+		}
+		if len(src) == 0 {
+			t.Errorf("no source read for %s", tt.importpath)
+		}
+	}
+
+	_, _, _, err := ResolveModule(path, []string{libdir}, "nothere")
+	expected := `cannot find module "nothere", tried:` +
+		"\n\t" + path + "/nothere.my" +
+		"\n\t" + path + "/nothere/module.my" +
+		"\n\t" + libdir + "/nothere.my" +
+		"\n\t" + libdir + "/nothere/module.my" +
+		"\n\t" + StdlibDir + "/nothere.my" +
+		"\n\t" + StdlibDir + "/nothere/module.my"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong resolution error. expected=%q, got=%v", expected, err)
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.Tokenliteral not 'let'. got=%q", s.TokenLiteral())
@@ -736,7 +882,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			`str(x) or i.find("abc")`,
-			"(str(x) or i.find(abc))",
+			"(str(x) or i.find(\"abc\"))",
 		},
 	}
 
@@ -969,3 +1115,218 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestStructStatement(t *testing.T) {
+	input := `struct Point { x, y = 0; fn norm(self) { self.x * self.x + self.y * self.y } }`
+	p := New(lexer.New(input), "")
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "Point" {
+		t.Errorf("struct name is not Point. got=%s", stmt.Name.Value)
+	}
+	if len(stmt.Fields) != 2 || stmt.Fields[0].Default != nil || stmt.Fields[1].Default == nil {
+		t.Errorf("struct does not have fields x and y = 0. got=%s", stmt)
+	}
+	if len(stmt.Methods) != 1 || stmt.Methods[0].Name.Value != "norm" {
+		t.Errorf("struct does not have the method norm. got=%s", stmt)
+	}
+
+	p = New(lexer.New(`struct Colored(Point) { color }`), "")
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	embedding := program.Statements[0].(*ast.StructStatement)
+	if embedding.Embeds == nil || embedding.Embeds.Value != "Point" {
+		t.Errorf("struct does not embed Point. got=%s", embedding)
+	}
+
+	// a semicolon may end the declaration, as it may a let
+	p = New(lexer.New(`struct P { x }; P(1)`), "")
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Errorf("expected 2 statements, got %d: %s", len(program.Statements), program.Source())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`struct P(1) { x }`, "expected next token to be IDENT, got INT instead"},
+		{`struct P { x, x }`, "struct P declares x more than once"},
+		{`struct P { fn f() { 1 } }`, "method f must take the receiver as its first parameter"},
+		{`struct P { 1 }`, "expected field or method in struct P, got INT instead"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input), "")
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 || p.Diagnostics()[0].Message != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestFieldAssignment(t *testing.T) {
+	p := New(lexer.New(`self.x = self.x + 1`), "")
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assign, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+	if assign.Object.String() != "self" || assign.Name.Value != "x" {
+		t.Errorf("assignment target is not self.x. got=%s", assign)
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	input := `select { case c.recv() as v { v } case d.send(1) { 2 } default { 3 } }`
+	p := New(lexer.New(input), "")
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	sel, ok := stmt.Expression.(*ast.SelectExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SelectExpression. got=%T", stmt.Expression)
+	}
+	if len(sel.Cases) != 2 {
+		t.Fatalf("select does not have 2 cases. got=%d", len(sel.Cases))
+	}
+	if sel.Cases[0].IsSend() || sel.Cases[0].Binding.Value != "v" {
+		t.Errorf("first case is not a recv bound to v. got=%s", sel.Cases[0])
+	}
+	if !sel.Cases[1].IsSend() || sel.Cases[1].Binding != nil {
+		t.Errorf("second case is not a send. got=%s", sel.Cases[1])
+	}
+	if sel.Default == nil {
+		t.Errorf("select has no default case")
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`select { case c.len() { 1 } }`, "select case must be a channel recv() or send(value) call"},
+		{`select { case c.send(1) as v { 1 } }`, "a send case in select can't bind a name"},
+		{`select { default { 1 } default { 2 } }`, "select has more than one default case"},
+		{`select { 1 }`, "expected case or default in select, got INT instead"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input), "")
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 || p.Diagnostics()[0].Message != tt.expected {
+			t.Errorf("%s: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	inputs := []string{
+		`let x = 1; let y = x * (2 + 3); puts(x); puts(y)`,
+		`let f = fn(a, b) { let c = a + b; puts(c); return c * 2 }`,
+		`if (x > 1) { puts("big") } else { puts('small {x}') }`,
+		`let h = {"a" -> 1, "b" -> [1, 2, {3, 4}]}; h["a"]`,
+		`let s = "hello"; s[1:3]; s[:2]; s[2:]; s.upper().lower()`,
+		`do { if (i == 10) { break } i = i + 1 }`,
+		`let st = struct(a -> 1, b -> "x"); st.a`,
+		`struct Point(Base) { x, y = 0; fn dist(self, other) { self.x - other.x } }`,
+		`self.x = self.x + 1`,
+		`import "lib/strings" as str; from "math" import max, min; export let z = 1; export x, y`,
+		`include mymodule`,
+		`select { case c.recv() as v { v } case d.send(1) { 2 } default { 3 } }`,
+		`!true and -x or not`,
+		`fn(x) { x }(5)`,
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input), "")
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		src := program.Source()
+		p = New(lexer.New(src), "")
+		reparsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%s: printed program doesn't parse: %v\n%s", input, p.Errors(), src)
+			continue
+		}
+		if second := reparsed.Source(); second != src {
+			t.Errorf("%s: printing is not stable.\nfirst=%s\nsecond=%s", input, src, second)
+		}
+	}
+}
+
+func TestUnterminatedInterpolatedStrings(t *testing.T) {
+	inputs := []string{`'a {x`, `puts('v is {x`, `'a {`, `'{x}`}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input), "")
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Hint != "the string is never closed" {
+			t.Errorf("%s: expected an unclosed string error, got %v", input, p.Errors())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := `let x = 5
+let = 10
+let y = (1 + ;
+let z = fn(a) {
+  let b == a
+  b
+}
+puts(x, "unclosed)
+`
+	p := New(lexer.New(input), "")
+	program := p.ParseProgram()
+
+	expected := []struct {
+		line, column int
+		message      string
+		expected     token.TokenType
+		hint         string
+	}{
+		{2, 5, "expected next token to be IDENT, got = instead", token.IDENT, ""},
+		{3, 14, "no prefix parse functions for ';' found", "", `an expression was expected, not ";"`},
+		{5, 9, "expected next token to be =, got == instead", token.ASSIGN, "use = to bind a name; == compares"},
+		{8, 9, "no prefix parse functions for 'ILLEGAL' found", "", "the string is never closed"},
+	}
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(expected), len(diagnostics), p.Errors())
+	}
+	for i, tt := range expected {
+		d := diagnostics[i]
+		if d.Line != tt.line || d.Column != tt.column || d.Message != tt.message || d.Expected != tt.expected || d.Hint != tt.hint {
+			t.Errorf("diagnostic %d wrong. expected=%+v, got=%+v", i, tt, *d)
+		}
+	}
+
+	// the statements with errors are dropped, the rest kept whole
+	statements := []string{"let x = 5;", "let z = fn (a) { b };"}
+	if len(program.Statements) != len(statements) {
+		t.Fatalf("expected %d statements, got %d: %s", len(statements), len(program.Statements), program.Source())
+	}
+	for i, s := range statements {
+		if program.Statements[i].String() != s {
+			t.Errorf("statement %d wrong. expected=%q, got=%q", i, s, program.Statements[i].String())
+		}
+	}
+
+	formatted := diagnostics[2].Format("prog.my", input)
+	want := "prog.my:5:9: expected next token to be =, got == instead\n" +
+		"      let b == a\n" +
+		"            ^\n" +
+		"    hint: use = to bind a name; == compares\n"
+	if formatted != want {
+		t.Errorf("Format wrong. expected=\n%s\ngot=\n%s", want, formatted)
+	}
+}
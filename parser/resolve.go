@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/stdlib"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StdlibDir is the pseudo directory that module paths inside the bundled
+// standard library are reported under.
+const StdlibDir = "<stdlib>"
+
+// SetIncludePaths sets the directories, e.g. from -I flags, that are searched
+// for included and imported modules after the including file's directory.
+func (p *Parser) SetIncludePaths(paths []string) {
+	p.includePaths = paths
+}
+
+// searchPath returns the directories searched for a module, in order: dir,
+// which is the directory of the file doing the importing, the include paths,
+// the entries of the MONKEYPATH environment variable and finally the bundled
+// standard library.
+func searchPath(dir string, includePaths []string, importpath string) []string {
+	if filepath.IsAbs(importpath) {
+		return []string{""}
+	}
+	dirs := []string{dir}
+	dirs = append(dirs, includePaths...)
+	for _, d := range filepath.SplitList(os.Getenv("MONKEYPATH")) {
+		if d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	return append(dirs, StdlibDir)
+}
+
+// ResolveModule finds importpath on the search path. In each directory it
+// tries importpath.my before importpath/module.my, and the first match wins.
+// It returns the absolute path of the matched file, its contents and whether
+// it is a directory module. If nothing matches, the error lists every path
+// that was tried.
+func ResolveModule(dir string, includePaths []string, importpath string) (string, []byte, bool, error) {
+	tried := []string{}
+	for _, d := range searchPath(dir, includePaths, importpath) {
+		candidates := []struct {
+			name   string
+			module bool
+		}{
+			{importpath + ".my", false},
+			{filepath.Join(importpath, "module.my"), true},
+		}
+		for _, c := range candidates {
+			filename := filepath.Join(d, c.name)
+			tried = append(tried, filename)
+			if src, err := ReadModuleFile(filename); err == nil {
+				return absModulePath(filename), src, c.module, nil
+			}
+		}
+	}
+	return "", nil, false, fmt.Errorf("cannot find module %q, tried:\n\t%s", importpath, strings.Join(tried, "\n\t"))
+}
+
+// ReadModuleFile reads a module file found by ResolveModule, which may live
+// in the bundled standard library.
+func ReadModuleFile(filename string) ([]byte, error) {
+	if strings.HasPrefix(filename, StdlibDir+"/") {
+		return stdlib.FS.ReadFile(strings.TrimPrefix(filename, StdlibDir+"/"))
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filename)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func absModulePath(filename string) string {
+	if strings.HasPrefix(filename, StdlibDir+"/") {
+		return filename
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filename
+}
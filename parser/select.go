@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	sel := &ast.SelectExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.CASE:
+			c := p.parseSelectCase()
+			if c == nil {
+				return nil
+			}
+			sel.Cases = append(sel.Cases, c)
+		case token.DEFAULT:
+			if sel.Default != nil {
+				p.errorAt(p.curToken, "select has more than one default case")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			sel.Default = p.parseBlockStatement().(*ast.BlockStatement)
+		default:
+			msg := fmt.Sprintf("expected case or default in select, got %s instead", p.curToken.Type)
+			p.errorAt(p.curToken, msg)
+			return nil
+		}
+		if p.peekTokenIs(token.EOF) {
+			p.peekError(token.RBRACE)
+			return nil
+		}
+		p.nextToken()
+	}
+	return sel
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Token: p.curToken}
+	p.nextToken()
+	call, ok := p.parseExpression(LOWEST).(*ast.MethodCallExpression)
+	if !ok || !isChannelOperation(call) {
+		p.errorAt(p.curToken, "select case must be a channel recv() or send(value) call")
+		return nil
+	}
+	c.Call = call
+	if p.peekTokenIs(token.AS) {
+		if c.IsSend() {
+			p.errorAt(p.curToken, "a send case in select can't bind a name")
+			return nil
+		}
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		c.Binding = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	c.Body = p.parseBlockStatement().(*ast.BlockStatement)
+	return c
+}
+
+func isChannelOperation(call *ast.MethodCallExpression) bool {
+	ce, ok := call.Call.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	switch ce.Function.String() {
+	case "recv":
+		return len(ce.Arguments) == 0
+	case "send":
+		return len(ce.Arguments) == 1
+	}
+	return false
+}
@@ -2,11 +2,8 @@ package parser
 
 import (
 	"fmt"
-	"io/ioutil"
 	"monkey/ast"
-	"monkey/lexer"
 	"monkey/token"
-	"os"
 )
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
@@ -22,7 +19,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseBreakWithoutLoopContext() ast.Expression {
-	p.errors = append(p.errors, "'break' outside of loop context")
+	p.errorAt(p.curToken, "'break' outside of loop context")
 	return p.parseBreakExpression()
 }
 
@@ -50,9 +47,12 @@ func (p *Parser) parseAssignExpression(name ast.Expression) ast.Expression {
 
 	if n, ok := name.(*ast.Identifier); ok {
 		e.Name = n
+	} else if field, ok := fieldAccess(name); ok {
+		e.Object = field.Object
+		e.Name = field.Call.(*ast.Identifier)
 	} else {
 		msg := fmt.Sprintf("expected assign token to be IDENT, got %s instead", name.TokenLiteral())
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, msg)
 	}
 	p.nextToken()
 	e.Value = p.parseExpression(LOWEST)
@@ -60,43 +60,21 @@ func (p *Parser) parseAssignExpression(name ast.Expression) ast.Expression {
 	return e
 }
 
+// fieldAccess reports whether e is a field access such as `self.x`.
+func fieldAccess(e ast.Expression) (*ast.MethodCallExpression, bool) {
+	mc, ok := e.(*ast.MethodCallExpression)
+	if !ok {
+		return nil, false
+	}
+	_, ok = mc.Call.(*ast.Identifier)
+	return mc, ok
+}
+
 func (p *Parser) parseIncludeStatement() *ast.IncludeStatement {
-	stmt := &ast.IncludeStatement{Token: p.curToken}
+	stmt := &ast.IncludeStatement{Token: p.curToken, Dir: p.path, IncludePaths: p.includePaths}
 
 	if p.expectPeek(token.IDENT) {
 		stmt.IncludePath = p.parseExpressionStatement().Expression
 	}
-	program, module, err := p.getIncludedStatements(stmt.IncludePath.String())
-	if err != nil {
-		p.errors = append(p.errors, err.Error())
-	}
-	stmt.Program = program
-	stmt.IsModule = module
 	return stmt
 }
-
-func (p *Parser) getIncludedStatements(importpath string) (*ast.Program, bool, error) {
-	module := false
-	path := p.path
-	f, err := ioutil.ReadFile(path + "/" + importpath + ".my")
-	if err != nil {
-		path = path + "/" + importpath
-		_, err := os.Stat(path)
-		if err != nil {
-			return nil, module, fmt.Errorf("no file or directory: %s.my, %s", importpath, path)
-		}
-		m, err := ioutil.ReadFile(path + "/module.my")
-		if err != nil {
-			return nil, module, err
-		}
-		module = true
-		f = m
-	}
-	l := lexer.New(string(f))
-	ps := New(l, path)
-	parsed := ps.ParseProgram()
-	if len(ps.errors) != 0 {
-		p.errors = append(p.errors, ps.errors...)
-	}
-	return parsed, module, nil
-}
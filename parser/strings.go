@@ -24,6 +24,10 @@ func (p *Parser) parseInterpolatedString() ast.Expression {
 		if p.curTokenIs(token.ISTRING) {
 			break
 		}
+		if p.curTokenIs(token.EOF) {
+			p.errorAt(is.Token, "unexpected end of input in interpolated string")
+			return nil
+		}
 	}
 	return is
 }
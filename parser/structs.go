@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+// parseStructStatement parses a struct type declaration. The name may be
+// followed by an embedded type in parentheses. Fields are separated by commas
+// or semicolons and may have a default value; methods are declared as
+// `fn name(self, ...) { ... }`.
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Embeds = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	seen := make(map[string]bool)
+	for !p.curTokenIs(token.RBRACE) {
+		var name string
+		switch p.curToken.Type {
+		case token.IDENT:
+			field := &ast.StructField{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+			if p.peekTokenIs(token.ASSIGN) {
+				p.nextToken()
+				p.nextToken()
+				field.Default = p.parseExpression(LOWEST)
+			}
+			name = field.Name.Value
+			stmt.Fields = append(stmt.Fields, field)
+		case token.FUNCTION:
+			method := p.parseStructMethod()
+			if method == nil {
+				return nil
+			}
+			name = method.Name.Value
+			stmt.Methods = append(stmt.Methods, method)
+		case token.COMMA, token.SEMICOLON:
+		default:
+			msg := fmt.Sprintf("expected field or method in struct %s, got %s instead", stmt.Name.Value, p.curToken.Type)
+			p.errorAt(p.curToken, msg)
+			return nil
+		}
+		if name != "" {
+			if seen[name] {
+				p.errorAt(p.curToken, fmt.Sprintf("struct %s declares %s more than once", stmt.Name.Value, name))
+				return nil
+			}
+			seen[name] = true
+		}
+		if p.peekTokenIs(token.EOF) {
+			p.peekError(token.RBRACE)
+			return nil
+		}
+		p.nextToken()
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseStructMethod() *ast.StructMethod {
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	method := &ast.StructMethod{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, Function: fn}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	fn.Parameters = p.parseExpressionArray(fn.Parameters, token.RPAREN)
+	if len(fn.Parameters) == 0 {
+		p.errorAt(p.curToken, fmt.Sprintf("method %s must take the receiver as its first parameter", method.Name.Value))
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	fn.Body = p.parseBlockStatement().(*ast.BlockStatement)
+	return method
+}
@@ -0,0 +1,169 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// command is a REPL meta-command, entered as `:name argument`.
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help": {
+			usage: ":help",
+			help:  "list the REPL commands",
+			run: func(s *session, arg string) {
+				names := []string{}
+				for name := range commands {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					fmt.Fprintf(s.out, "%-16s %s\n", commands[name].usage, commands[name].help)
+				}
+				fmt.Fprintf(s.out, "%-16s %s\n", ":quit", "leave the REPL")
+			},
+		},
+		"env": {
+			usage: ":env",
+			help:  "list the bindings in the global scope",
+			run: func(s *session, arg string) {
+				scope := s.in.Scope()
+				for _, name := range scope.Names() {
+					value, _ := scope.Get(name)
+					fmt.Fprintf(s.out, "%s: %s\n", name, value.Type())
+				}
+			},
+		},
+		"type": {
+			usage: ":type expr",
+			help:  "print the type of expr",
+			run: func(s *session, arg string) {
+				result, err := s.in.Eval(arg)
+				if s.printError(err) {
+					fmt.Fprintln(s.out, result.Type())
+				}
+			},
+		},
+		"ast": {
+			usage: ":ast expr",
+			help:  "print the syntax tree of expr",
+			run: func(s *session, arg string) {
+				p := parser.New(lexer.New(arg), s.dir)
+				program := p.ParseProgram()
+				if len(p.Errors()) != 0 {
+					printParserErrors(s.out, p.Errors())
+					return
+				}
+				fmt.Fprint(s.out, ast.Dump(program))
+			},
+		},
+		"tokens": {
+			usage: ":tokens expr",
+			help:  "print the tokens of expr",
+			run: func(s *session, arg string) {
+				l := lexer.New(arg)
+				for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+					fmt.Fprintf(s.out, "%-8s %q\n", tok.Type, tok.Literal)
+				}
+			},
+		},
+		"load": {
+			usage: ":load file",
+			help:  "evaluate a file in the global scope",
+			run: func(s *session, arg string) {
+				if arg == "" {
+					printParserErrors(s.out, []string{"usage: :load file"})
+					return
+				}
+				path := s.path(arg)
+				src, err := ioutil.ReadFile(path)
+				if err != nil {
+					printParserErrors(s.out, []string{err.Error()})
+					return
+				}
+				result, err := s.in.EvalFile(path)
+				if s.printError(err) {
+					s.print(result)
+					s.entries = append(s.entries, strings.TrimRight(string(src), "\n"))
+				}
+			},
+		},
+		"reset": {
+			usage: ":reset",
+			help:  "drop every binding and start a new session",
+			run: func(s *session, arg string) {
+				if err := s.reset(); err != nil {
+					printParserErrors(s.out, []string{err.Error()})
+					return
+				}
+				s.loadRC()
+			},
+		},
+		"time": {
+			usage: ":time expr",
+			help:  "evaluate expr and print how long it took",
+			run: func(s *session, arg string) {
+				start := time.Now()
+				if s.eval(arg) {
+					s.entries = append(s.entries, arg)
+				}
+				fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+			},
+		},
+		"save": {
+			usage: ":save file",
+			help:  "write the input evaluated so far to a file",
+			run: func(s *session, arg string) {
+				if arg == "" {
+					printParserErrors(s.out, []string{"usage: :save file"})
+					return
+				}
+				src := strings.Join(s.entries, "\n") + "\n"
+				if err := ioutil.WriteFile(s.path(arg), []byte(src), 0644); err != nil {
+					printParserErrors(s.out, []string{err.Error()})
+					return
+				}
+				fmt.Fprintf(s.out, "saved %d entries to %s\n", len(s.entries), arg)
+			},
+		},
+	}
+}
+
+// command runs a line starting with a colon.
+func (s *session) command(line string) {
+	name, arg := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+	c, ok := commands[name]
+	if !ok {
+		printParserErrors(s.out, []string{fmt.Sprintf("unknown command :%s, see :help", name)})
+		return
+	}
+	c.run(s, arg)
+}
+
+// path resolves a file name given to a command against the session's
+// directory.
+func (s *session) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
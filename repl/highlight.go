@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/eval"
+	"monkey/token"
+)
+
+// ANSI escape sequences used to highlight output
+const (
+	colorKeyword = "\x1b[35m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[36m"
+	colorError   = "\x1b[31m"
+	colorReset   = "\x1b[0m"
+)
+
+// highlightResult colors a value printed by the REPL. Strings are shown
+// whole in the string color rather than scanned as source.
+func highlightResult(obj eval.Object) string {
+	switch obj.(type) {
+	case *eval.String:
+		return colorString + obj.Inspect() + colorReset
+	case *eval.Integer:
+		return colorNumber + obj.Inspect() + colorReset
+	case *eval.Boolean, *eval.Null:
+		return colorKeyword + obj.Inspect() + colorReset
+	case *eval.Error:
+		return colorError + obj.Inspect() + colorReset
+	case *eval.Function:
+		return highlight(obj.Inspect())
+	}
+	return obj.Inspect()
+}
+
+// highlight colors the keywords, strings and numbers in src.
+func highlight(src string) string {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				end++
+			}
+			if end < len(src) {
+				end++
+			}
+			out.WriteString(colorString + src[i:end] + colorReset)
+			i = end
+		case isWordChar(rune(c)):
+			end := i
+			for end < len(src) && isWordChar(rune(src[end])) {
+				end++
+			}
+			word := src[i:end]
+			switch {
+			case '0' <= c && c <= '9':
+				out.WriteString(colorNumber + word + colorReset)
+			case token.LookupIdent(word) != token.IDENT:
+				out.WriteString(colorKeyword + word + colorReset)
+			default:
+				out.WriteString(word)
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is the number of entries kept in the history file
+const maxHistory = 1000
+
+// historyPath returns where REPL history is kept: $XDG_STATE_HOME/monkey/history
+// if XDG_STATE_HOME is set, otherwise ~/.monkey_history.
+func historyPath() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "monkey", "history"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".monkey_history"), nil
+}
+
+// rcPath returns the file evaluated when the REPL starts: $MONKEYRC if set,
+// otherwise ~/.monkeyrc.my.
+func rcPath() string {
+	if path := os.Getenv("MONKEYRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".monkeyrc.my")
+}
+
+// readHistory returns the entries in the history file at path. A file that
+// has grown past maxHistory entries is trimmed to the latest ones.
+func readHistory(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(entries) == 1 && entries[0] == "" {
+		return nil, nil
+	}
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+		if err := ioutil.WriteFile(path, []byte(strings.Join(entries, "\n")+"\n"), 0600); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+// appendHistory adds entry to the history file at path, so history survives
+// however the REPL exits. Entries spanning several lines are joined into one.
+func appendHistory(path, entry string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(historyEntry(entry) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// historyEntry turns a multi-line entry into the single line it is
+// remembered as.
+func historyEntry(entry string) string {
+	lines := strings.Split(entry, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,77 @@
+package repl
+
+import (
+	"monkey/eval"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// incomplete reports whether src ends inside a string or with unclosed
+// braces, parentheses or brackets, so the REPL should read another line
+// before parsing it.
+func incomplete(src string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+	}
+	return quote != 0 || depth > 0
+}
+
+func isWordChar(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// completer completes the word before the cursor with the names bound in
+// scope, builtins and keywords, or after a dot with the methods of the value
+// bound to the name before the dot. pos counts runes, not bytes.
+func completer(scope *eval.Scope) func(line string, pos int) (string, []string, string) {
+	return func(line string, pos int) (string, []string, string) {
+		runes := []rune(line)
+		head, tail := string(runes[:pos]), string(runes[pos:])
+		start := pos
+		for start > 0 && isWordChar(runes[start-1]) {
+			start--
+		}
+		prefix := string(runes[start:pos])
+		var candidates []string
+		if start > 0 && runes[start-1] == '.' {
+			obj := start - 1
+			for obj > 0 && isWordChar(runes[obj-1]) {
+				obj--
+			}
+			value, ok := scope.Get(string(runes[obj : start-1]))
+			if !ok {
+				return head, nil, tail
+			}
+			candidates = eval.MethodNames(value)
+		} else {
+			candidates = append(append(scope.Names(), eval.BuiltinNames()...), token.Keywords()...)
+		}
+		completions := []string{}
+		seen := make(map[string]bool)
+		for _, c := range candidates {
+			if strings.HasPrefix(c, prefix) && !seen[c] {
+				seen[c] = true
+				completions = append(completions, c)
+			}
+		}
+		sort.Strings(completions)
+		return string(runes[:start]), completions, tail
+	}
+}
@@ -3,56 +3,182 @@ package repl
 import (
 	"io"
 	"monkey/eval"
-	"monkey/lexer"
-	"monkey/parser"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/peterh/liner"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT = ">> "
+	// CONTINUE is shown while reading the rest of an incomplete entry
+	CONTINUE = ".. "
+)
+
+// session is the state of a REPL: the interpreter running the input and the
+// input evaluated so far, which :save writes out.
+type session struct {
+	out          io.Writer
+	in           *eval.Interpreter
+	dir          string
+	includePaths []string
+	color        bool
+	entries      []string
+	// rc is evaluated at startup and after :reset
+	rc string
+	// exited is set when the program calls exit()
+	exited   bool
+	exitCode int
+}
+
+func newSession(out io.Writer, dir string, includePaths []string) (*session, error) {
+	s := &session{out: out, dir: dir, includePaths: includePaths}
+	return s, s.reset()
+}
+
+// reset replaces the interpreter, dropping every binding.
+func (s *session) reset() error {
+	in, err := eval.NewInterpreter(eval.Options{Stdout: s.out, Dir: s.dir, IncludePaths: s.includePaths})
+	if err != nil {
+		return err
+	}
+	if s.in != nil {
+		s.in.Close()
+	}
+	s.in = in
+	s.entries = nil
+	return nil
+}
 
-func Start(out io.Writer) {
-	history := filepath.Join(os.TempDir(), ".monkey_history")
+// loadRC evaluates the session's rc file, if it exists, printing any errors.
+func (s *session) loadRC() {
+	if s.rc == "" {
+		return
+	}
+	if _, err := os.Stat(s.rc); os.IsNotExist(err) {
+		return
+	}
+	_, err := s.in.EvalFile(s.rc)
+	s.printError(err)
+}
+
+// eval evaluates src and prints the result. It reports whether src
+// evaluated without errors.
+func (s *session) eval(src string) bool {
+	result, err := s.in.Eval(src)
+	if !s.printError(err) {
+		return false
+	}
+	s.print(result)
+	return true
+}
+
+// printError prints err, returning false if there was one.
+func (s *session) printError(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return true
+	case *eval.ExitError:
+		s.exited, s.exitCode = true, e.Code
+	case *eval.ParseError:
+		printParserErrors(s.out, e.Errors)
+	case *eval.Error:
+		s.print(e)
+	default:
+		io.WriteString(s.out, "\t"+err.Error()+"\n")
+	}
+	return false
+}
+
+func (s *session) print(obj eval.Object) {
+	result := obj.Inspect()
+	if s.color {
+		result = highlightResult(obj)
+	}
+	io.WriteString(s.out, result)
+	io.WriteString(s.out, "\n")
+}
+
+// Start runs the REPL until the user quits or the program calls exit(), and
+// returns the exit status.
+func Start(out io.Writer, includePaths []string) int {
 	l := liner.NewLiner()
 	defer l.Close()
 
 	l.SetCtrlCAborts(true)
 
-	if f, err := os.Open(history); err == nil {
-		l.ReadHistory(f)
-		f.Close()
+	history, err := historyPath()
+	if err == nil {
+		var entries []string
+		if entries, err = readHistory(history); len(entries) > 0 {
+			l.ReadHistory(strings.NewReader(strings.Join(entries, "\n")))
+		}
+	}
+	if err != nil {
+		printParserErrors(out, []string{"history is not saved: " + err.Error()})
+		history = ""
+	}
+	remember := func(entry string) {
+		l.AppendHistory(historyEntry(entry))
+		if history != "" {
+			appendHistory(history, entry)
+		}
 	}
 
-	scope := eval.NewScope(nil)
 	wd, err := os.Getwd()
 	if err != nil {
 		io.WriteString(out, err.Error())
-		os.Exit(1)
+		return 1
 	}
+	s, err := newSession(out, wd, includePaths)
+	if err != nil {
+		io.WriteString(out, err.Error())
+		return 1
+	}
+	s.color = liner.TerminalSupported() && os.Getenv("NO_COLOR") == ""
+	s.rc = rcPath()
+	s.loadRC()
+	// the completer looks up the current interpreter, which :reset replaces
+	l.SetWordCompleter(func(line string, pos int) (string, []string, string) {
+		return completer(s.in.Scope())(line, pos)
+	})
+	var lines []string
 	for {
-		if line, err := l.Prompt(PROMPT); err == nil {
-			if line == "exit" {
-				if f, err := os.Create(history); err == nil {
-					l.WriteHistory(f)
-					f.Close()
-				}
-				break
-			}
-			l.AppendHistory(line)
-			lex := lexer.New(line)
-			p := parser.New(lex, wd)
-			program := p.ParseProgram()
-			if len(p.Errors()) != 0 {
-				printParserErrors(out, p.Errors())
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUE
+		}
+		line, err := l.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			// Ctrl-C discards a partly entered definition
+			lines = nil
+			continue
+		} else if err != nil {
+			break
+		}
+		if len(lines) == 0 && (line == "exit" || line == ":quit") {
+			break
+		}
+		if len(lines) == 0 && strings.HasPrefix(line, ":") {
+			remember(line)
+			s.command(line)
+		} else {
+			lines = append(lines, line)
+			src := strings.Join(lines, "\n")
+			if incomplete(src) {
 				continue
 			}
-			e := eval.Eval(program, scope)
-			io.WriteString(out, e.Inspect())
-			io.WriteString(out, "\n")
+			lines = nil
+			remember(src)
+			if s.eval(src) {
+				s.entries = append(s.entries, src)
+			}
+		}
+		if s.exited {
+			return s.exitCode
 		}
 	}
+	return 0
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,198 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`let f = fn(x) {`, true},
+		{"let f = fn(x) {\n x + 1\n}", false},
+		{`let a = [1, 2,`, true},
+		{`puts("a {`, true},
+		{`puts("a {")`, false},
+		{`'x is {x`, true},
+		{`1 + 2`, false},
+		{`}`, false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) = %t, expected %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	scope := eval.NewScope(nil)
+	program := parser.New(lexer.New(`let counter = 1; let country = "nl"`), "").ParseProgram()
+	eval.Eval(program, scope)
+	complete := completer(scope)
+
+	tests := []struct {
+		line        string
+		head        string
+		completions []string
+	}{
+		{"coun", "", []string{"counter", "country"}},
+		{"puts(cou", "puts(", []string{"counter", "country"}},
+		{"le", "", []string{"len", "let"}},
+		{"country.up", "country.", []string{"upper"}},
+		{"country.starts", "country.", []string{"starts_with"}},
+		{"nothing.x", "nothing.x", nil},
+		// the position counts runes, so text with multibyte characters completes
+		{`puts("é", cou`, `puts("é", `, []string{"counter", "country"}},
+	}
+
+	for _, tt := range tests {
+		head, completions, tail := complete(tt.line, len([]rune(tt.line)))
+		if head != tt.head || tail != "" {
+			t.Errorf("%q: wrong head or tail. got=%q, %q", tt.line, head, tail)
+		}
+		if !reflect.DeepEqual(completions, tt.completions) && len(completions)+len(tt.completions) != 0 {
+			t.Errorf("%q: wrong completions. expected=%v, got=%v", tt.line, tt.completions, completions)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	input := `fn(x) { if (x) { "yes" } else { 10 } }`
+	expected := colorKeyword + "fn" + colorReset + "(x) { " +
+		colorKeyword + "if" + colorReset + " (x) { " +
+		colorString + `"yes"` + colorReset + " } " +
+		colorKeyword + "else" + colorReset + " { " +
+		colorNumber + "10" + colorReset + " } }"
+	if got := highlight(input); got != expected {
+		t.Errorf("wrong highlighting. expected=%q, got=%q", expected, got)
+	}
+	if got := highlightResult(&eval.String{Value: "if"}); got != colorString+"if"+colorReset {
+		t.Errorf("string results should be highlighted whole. got=%q", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.my"), []byte("let double = fn(x) { x * 2 }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	s, err := newSession(&out, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 5`, "5\n"},
+		{`:type x`, "INTEGER\n"},
+		{`:type "a"`, "STRING\n"},
+		{`:env`, "x: INTEGER\n"},
+		{`:tokens let y`, "LET      \"let\"\nIDENT    \"y\"\n"},
+		{`:ast x`, "Program\n  Statements[0]: ExpressionStatement\n    Expression: Identifier x\n"},
+		{`:load lib.my`, "fn (x) { (x * 2) }\n"},
+		{`double(x)`, "10\n"},
+		{`:save session.my`, "saved 3 entries to session.my\n"},
+		{`:reset`, ""},
+		{`x`, "Err: unknown identifier: 'x' is not defined\n"},
+		{`:load session.my`, "10\n"},
+		{`:type 1 +`, "\t1:4: no prefix parse functions for 'EOF' found\n"},
+		{`:nope`, "\tunknown command :nope, see :help\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		if strings.HasPrefix(tt.input, ":") {
+			s.command(tt.input)
+		} else if s.eval(tt.input) {
+			s.entries = append(s.entries, tt.input)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.input, tt.expected, out.String())
+		}
+	}
+
+	out.Reset()
+	s.command(":time 1 + 1")
+	if !strings.HasPrefix(out.String(), "2\ntook ") {
+		t.Errorf(":time printed %q", out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "history")
+
+	if entries, err := readHistory(path); err != nil || entries != nil {
+		t.Fatalf("missing history file should be empty. got=%v, %v", entries, err)
+	}
+	for i := 0; i < maxHistory+5; i++ {
+		if err := appendHistory(path, fmt.Sprintf("let x = %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := appendHistory(path, "let f = fn(x) {\n  x\n}"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxHistory {
+		t.Fatalf("history is not capped at %d entries. got=%d", maxHistory, len(entries))
+	}
+	if entries[0] != "let x = 6" || entries[len(entries)-1] != "let f = fn(x) { x }" {
+		t.Errorf("wrong entries kept. first=%q, last=%q", entries[0], entries[len(entries)-1])
+	}
+	if again, _ := readHistory(path); len(again) != maxHistory {
+		t.Errorf("history file was not trimmed. got=%d entries", len(again))
+	}
+}
+
+func TestRCFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-rc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rc := filepath.Join(dir, "rc.my")
+	if err := ioutil.WriteFile(rc, []byte(`let greeting = "hi"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	s, err := newSession(&out, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.rc = rc
+	s.loadRC()
+	s.command(":reset")
+	out.Reset()
+	s.eval("greeting")
+	if out.String() != "hi\n" {
+		t.Errorf("rc file was not loaded after :reset. got=%q", out.String())
+	}
+}
@@ -0,0 +1,11 @@
+// Package stdlib bundles the standard library modules that every monkey
+// program can import, regardless of MONKEYPATH or -I flags.
+package stdlib
+
+import "embed"
+
+// FS holds the bundled .my modules, laid out as they are imported, e.g.
+// `import math` resolves to math.my.
+//
+//go:embed *.my
+var FS embed.FS
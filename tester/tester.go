@@ -0,0 +1,199 @@
+// Package tester discovers and runs the test functions in *_test.my files.
+//
+// A test function is a function whose name starts with "test_", bound at the
+// top level of a test file or of a module the file includes. It passes when
+// calling it doesn't end in an error, such as a failed assert.
+package tester
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Suffix ends the names of test files.
+	Suffix = "_test.my"
+	// Prefix starts the names of test functions.
+	Prefix = "test_"
+)
+
+// Options configures a test run.
+type Options struct {
+	// Out receives the report; output printed by the tests goes to Stdout.
+	Out          io.Writer
+	Stdout       io.Writer
+	IncludePaths []string
+}
+
+// Result counts the outcomes of a test run.
+type Result struct {
+	Passed int
+	Failed int
+}
+
+// Find returns the test files named by paths. Directories are searched
+// recursively; files are returned whatever their name.
+func Find(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() && strings.HasSuffix(p, Suffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run runs the tests in each file, reporting to opts.Out.
+func Run(files []string, opts Options) Result {
+	var total Result
+	for _, file := range files {
+		r := RunFile(file, opts)
+		total.Passed += r.Passed
+		total.Failed += r.Failed
+	}
+	return total
+}
+
+// RunFile runs the test functions in file, those defined in the file itself
+// in the order they are defined, then those of the modules it includes. Each
+// test runs in a new interpreter that has evaluated the file again, so tests
+// can't see each other's changes to globals or modules. A file that doesn't
+// evaluate counts as one failure.
+func RunFile(file string, opts Options) Result {
+	start := time.Now()
+	var r Result
+	fail := func(msg string) {
+		r.Failed++
+		fmt.Fprintf(opts.Out, "\t%s\n", msg)
+	}
+	names, err := discover(file, opts)
+	if err != nil {
+		fail(file + ": " + err.Error())
+	}
+	for _, name := range names {
+		testStart := time.Now()
+		err := runTest(file, name, opts)
+		elapsed := time.Since(testStart).Seconds()
+		if err != nil {
+			fmt.Fprintf(opts.Out, "--- FAIL: %s (%.3fs)\n", name, elapsed)
+			fail(err.Error())
+			continue
+		}
+		r.Passed++
+		fmt.Fprintf(opts.Out, "--- PASS: %s (%.3fs)\n", name, elapsed)
+	}
+
+	status := "ok  "
+	if r.Failed > 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(opts.Out, "%s\t%s\t%.3fs\n", status, file, time.Since(start).Seconds())
+	return r
+}
+
+// setup returns a new interpreter that has evaluated file, discarding what
+// the file prints.
+func setup(file string, opts Options) (*eval.Interpreter, error) {
+	in, err := eval.NewInterpreter(eval.Options{Stdout: ioutil.Discard, IncludePaths: opts.IncludePaths})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := in.EvalFile(file); err != nil {
+		in.Close()
+		return nil, err
+	}
+	return in, nil
+}
+
+// runTest calls the test function name, which is qualified with the
+// module's name for tests in included modules.
+func runTest(file, name string, opts Options) error {
+	in, err := setup(file, opts)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if opts.Stdout != nil {
+		in.Scope().SetWriter(opts.Stdout)
+	}
+	_, err = in.Eval(name + "()")
+	return err
+}
+
+// discover returns the names of the test functions in file.
+func discover(file string, opts Options) ([]string, error) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)), "")
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &eval.ParseError{Errors: p.Errors(), Diagnostics: p.Diagnostics()}
+	}
+	in, err := setup(file, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	scope := in.Scope()
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, s := range program.Statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok || seen[let.Name.Value] {
+			continue
+		}
+		if obj, _ := scope.Get(let.Name.Value); isTest(let.Name.Value, obj) {
+			seen[let.Name.Value] = true
+			names = append(names, let.Name.Value)
+		}
+	}
+	for _, module := range scope.Names() {
+		obj, _ := scope.Get(module)
+		included, ok := obj.(*eval.IncludedObject)
+		if !ok {
+			continue
+		}
+		for _, name := range included.Scope.Names() {
+			if obj, _ := included.Get(name); isTest(name, obj) {
+				names = append(names, module+"."+name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// isTest reports whether obj, bound to name, is a test function.
+func isTest(name string, obj eval.Object) bool {
+	_, ok := obj.(*eval.Function)
+	return ok && strings.HasPrefix(name, Prefix)
+}
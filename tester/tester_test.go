@@ -0,0 +1,99 @@
+package tester
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "monkey-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFind(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.my":     "",
+		"a.my":          "",
+		"sub/b_test.my": "",
+	})
+	defer os.RemoveAll(dir)
+
+	files, err := Find([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(dir, "a_test.my"), filepath.Join(dir, "sub", "b_test.my")}
+	if strings.Join(files, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.my": `include helpers
+let add = fn(a, b) { a + b }
+let total = 0
+let test_add = fn() { assert_eq(add(1, 2), 3) }
+let helper = fn() { exit(1) }
+let test_broken = fn() { assert(add(1, 2) == 4) }
+let test_sets_total = fn() { total = 5; assert(total == 5) }
+let test_sees_fresh_total = fn() { assert_eq(total, 0) }
+`,
+		"helpers.my":  "let test_helper = fn() { assert(true) }\nlet testing = 1\n",
+		"bad_test.my": "let = 1",
+	})
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file     string
+		expected Result
+		lines    []string
+	}{
+		{"math_test.my", Result{Passed: 4, Failed: 1}, []string{
+			"--- PASS: test_add",
+			"--- FAIL: test_broken",
+			"assertion error: (add(1, 2) == 4) is false: 3 == 4",
+			"--- PASS: test_sets_total",
+			"--- PASS: test_sees_fresh_total",
+			"--- PASS: helpers.test_helper",
+			"FAIL\t" + filepath.Join(dir, "math_test.my"),
+		}},
+		{"bad_test.my", Result{Failed: 1}, []string{
+			"parse error",
+			"FAIL\t" + filepath.Join(dir, "bad_test.my"),
+		}},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r := RunFile(filepath.Join(dir, tt.file), Options{Out: &out, Stdout: ioutil.Discard})
+		if r != tt.expected {
+			t.Errorf("%s: expected %+v, got %+v\n%s", tt.file, tt.expected, r, out.String())
+		}
+		report := out.String()
+		for _, line := range tt.lines {
+			if !strings.Contains(report, line) {
+				t.Errorf("%s: report doesn't contain %q:\n%s", tt.file, line, report)
+			}
+		}
+		if strings.Contains(report, "helper:") || strings.Contains(report, "testing") {
+			t.Errorf("%s: ran a function that isn't a test:\n%s", tt.file, report)
+		}
+	}
+}
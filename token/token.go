@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
@@ -16,6 +18,8 @@ const (
 	ASTERISK = "*"
 	SLASH    = "/"
 	MOD      = "%"
+	PIPE     = "|"
+	AMP      = "&"
 
 	LT        = "<"
 	GT        = ">"
@@ -40,6 +44,10 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	INCLUDE  = "INCLUDE"
+	IMPORT   = "IMPORT"
+	FROM     = "FROM"
+	AS       = "AS"
+	EXPORT   = "EXPORT"
 	STRING   = "STRING"
 	ISTRING  = "ISTRING"
 	BYTES    = "BYTES"
@@ -48,6 +56,9 @@ const (
 	STRUCT   = "STRUCT"
 	DO       = "DO"
 	BREAK    = "BREAK"
+	SELECT   = "SELECT"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
 )
 
 var keywords = map[string]TokenType{
@@ -59,11 +70,18 @@ var keywords = map[string]TokenType{
 	"else":    ELSE,
 	"return":  RETURN,
 	"include": INCLUDE,
+	"import":  IMPORT,
+	"from":    FROM,
+	"as":      AS,
+	"export":  EXPORT,
 	"and":     AND,
 	"or":      OR,
 	"struct":  STRUCT,
 	"do":      DO,
 	"break":   BREAK,
+	"select":  SELECT,
+	"case":    CASE,
+	"default": DEFAULT,
 }
 
 type TokenType string
@@ -71,6 +89,19 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Line and Column, both counted from 1, locate the token's first byte
+	Line   int
+	Column int
+}
+
+// Keywords returns the language's keywords in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {